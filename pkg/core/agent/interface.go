@@ -15,11 +15,49 @@ type Memory struct {
 	Metadata  map[string]interface{}
 }
 
+// Thought types
+const (
+	ThoughtTypeFast = "fast"
+	ThoughtTypeSlow = "slow"
+)
+
 // Thought represents an agent's thought process
 type Thought struct {
 	Content   string
 	Type      string // "fast" or "slow"
 	Timestamp time.Time
+	// Reason is what escalated a slow thought, e.g. "novelty, conflict"
+	Reason string
+
+	// Memories and Observations are included in the prompt. Plugins add to
+	// them in PreThink, e.g. with memories retrieved for the current situation.
+	Memories     []string
+	Observations []string
+
+	// PromptID records the template version the thought was generated with
+	PromptID string
+}
+
+// Environment is what an agent can perceive of and do in the world
+type Environment interface {
+	GetState() map[string]interface{}
+	AvailableActions(agentID string) []string
+	IsValidAction(action interface{}) bool
+}
+
+type contextKey string
+
+const environmentKey contextKey = "agent-environment"
+
+// WithEnvironment makes the world visible to agents thinking with ctx
+func WithEnvironment(ctx context.Context, env Environment) context.Context {
+	return context.WithValue(ctx, environmentKey, env)
+}
+
+// EnvironmentFrom returns the environment attached to ctx, if any
+func EnvironmentFrom(ctx context.Context) Environment {
+	env, _ := ctx.Value(environmentKey).(Environment)
+	return env
 }
 
 // Agent defines the core interface for an agent in the system
@@ -27,6 +65,7 @@ type Agent interface {
 	// Core identity and state
 	GetID() string
 	GetName() string
+	GetTags() []string
 	GetState() map[string]interface{}
 
 	// Thought processes
@@ -0,0 +1,91 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Relationship describes how an agent initially relates to someone else
+type Relationship struct {
+	Name     string `toml:"name" yaml:"name"`
+	Relation string `toml:"relation" yaml:"relation"`
+	Notes    string `toml:"notes" yaml:"notes"`
+}
+
+// Persona is the structured identity of an agent, as in the seed
+// descriptions of the Generative Agents paper
+type Persona struct {
+	Name          string         `toml:"name" yaml:"name"`
+	Age           int            `toml:"age" yaml:"age"`
+	Occupation    string         `toml:"occupation" yaml:"occupation"`
+	Traits        []string       `toml:"traits" yaml:"traits"`
+	Biography     string         `toml:"biography" yaml:"biography"`
+	Values        []string       `toml:"values" yaml:"values"`
+	SpeakingStyle string         `toml:"speaking_style" yaml:"speaking_style"`
+	Relationships []Relationship `toml:"relationships" yaml:"relationships"`
+}
+
+// LoadPersona reads a persona from a .toml, .yaml or .yml file
+func LoadPersona(path string) (*Persona, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read persona: %w", err)
+	}
+
+	var p Persona
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".toml":
+		err = toml.Unmarshal(b, &p)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &p)
+	default:
+		return nil, fmt.Errorf("unsupported persona format: %s", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse persona %s: %w", path, err)
+	}
+	return &p, nil
+}
+
+// Describe renders the persona as prose for inclusion in prompts
+func (p *Persona) Describe() string {
+	if p == nil {
+		return ""
+	}
+
+	var lines []string
+	identity := p.Name
+	if p.Age > 0 {
+		identity += fmt.Sprintf(", age %d", p.Age)
+	}
+	if p.Occupation != "" {
+		identity += ", " + p.Occupation
+	}
+	lines = append(lines, identity+".")
+
+	if len(p.Traits) > 0 {
+		lines = append(lines, "Traits: "+strings.Join(p.Traits, ", ")+".")
+	}
+	if len(p.Values) > 0 {
+		lines = append(lines, "Values: "+strings.Join(p.Values, ", ")+".")
+	}
+	if p.Biography != "" {
+		lines = append(lines, strings.TrimSpace(p.Biography))
+	}
+	if p.SpeakingStyle != "" {
+		lines = append(lines, "Speaking style: "+p.SpeakingStyle+".")
+	}
+	for _, r := range p.Relationships {
+		line := fmt.Sprintf("%s is your %s.", r.Name, r.Relation)
+		if r.Notes != "" {
+			line += " " + r.Notes
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -2,37 +2,97 @@ package agent
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log/slog"
 	"simulacra/pkg/core/action"
+	"simulacra/pkg/core/event"
 	"simulacra/pkg/core/logger"
 	"simulacra/pkg/llm"
+	"simulacra/pkg/llm/contextwindow"
+	"simulacra/pkg/llm/prompt"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 )
 
+// Prompt sizing per thought type. Fast thoughts see less and say less.
+const (
+	fastMemoryLimit      = 5
+	fastObservationLimit = 5
+	fastThoughtTokens    = 150
+	slowThoughtTokens    = 600
+	// maxActionAttempts bounds re-prompts when the world rejects an action
+	maxActionAttempts = 3
+	// actionTokens is the room left for the structured action choice
+	actionTokens = 256
+	// sectionTokens covers the heading of each packed prompt section, which
+	// only renders when the section has content
+	sectionTokens = 16
+)
+
 type DefaultAgent struct {
 	id      string
 	name    string
+	tags    []string
 	state   map[string]interface{}
 	plugins []AgentPlugin
 	llm     llm.Provider
+	models  map[string]string // thought type to model
+	persona *Persona
+	prompts *prompt.Library
+	sched   *scheduler
+	bus     event.Bus
+	now     func() time.Time
 	log     *slog.Logger
 	mu      sync.RWMutex
+
+	// Observations gathered since the last thought
+	observations []string
+	lastThought  *Thought
 }
 
 type Config struct {
-	ID      string
-	Name    string
+	ID   string
+	Name string
+	// Tags label the agent for routing, e.g. to send a group of agents to a
+	// particular backend with router.Route.Tags
+	Tags    []string
 	LLM     llm.Provider
 	Logger  *slog.Logger
 	Plugins []AgentPlugin
+
+	// Persona is included in every prompt. Its name is used when Name is
+	// empty.
+	Persona *Persona
+
+	// Model is passed to the provider; empty uses the provider default.
+	// FastModel and SlowModel override it for each kind of thought.
+	Model     string
+	FastModel string
+	SlowModel string
+	// Scheduler decides when to think slowly, defaults to
+	// DefaultSchedulerConfig
+	Scheduler *SchedulerConfig
+	// Prompts defaults to the built-in template library
+	Prompts *prompt.Library
+	// EventBus, if set, receives thoughts as they are generated
+	EventBus event.Bus
+	// Now is the simulation clock, e.g. Simulation.Now, used to stamp
+	// thoughts and tell the agent the time. Without it prompts carry no
+	// time. Wall-clock time would make every prompt unique and break
+	// replaying recorded runs.
+	Now func() time.Time
 }
 
 func NewDefaultAgent(cfg Config) (*DefaultAgent, error) {
 	if cfg.ID == "" {
 		return nil, fmt.Errorf("agent ID is required")
 	}
+	if cfg.Name == "" && cfg.Persona != nil {
+		cfg.Name = cfg.Persona.Name
+	}
 	if cfg.Name == "" {
 		return nil, fmt.Errorf("agent name is required")
 	}
@@ -50,12 +110,43 @@ func NewDefaultAgent(cfg Config) (*DefaultAgent, error) {
 		"agent_name", cfg.Name,
 	)
 
+	prompts := cfg.Prompts
+	if prompts == nil {
+		var err error
+		if prompts, err = prompt.NewLibrary(); err != nil {
+			return nil, err
+		}
+	}
+
+	sched := DefaultSchedulerConfig()
+	if cfg.Scheduler != nil {
+		sched = *cfg.Scheduler
+	}
+
+	models := map[string]string{
+		ThoughtTypeFast: cfg.Model,
+		ThoughtTypeSlow: cfg.Model,
+	}
+	if cfg.FastModel != "" {
+		models[ThoughtTypeFast] = cfg.FastModel
+	}
+	if cfg.SlowModel != "" {
+		models[ThoughtTypeSlow] = cfg.SlowModel
+	}
+
 	return &DefaultAgent{
 		id:      cfg.ID,
 		name:    cfg.Name,
+		tags:    cfg.Tags,
 		state:   make(map[string]interface{}),
 		plugins: cfg.Plugins,
 		llm:     cfg.LLM,
+		models:  models,
+		persona: cfg.Persona,
+		prompts: prompts,
+		sched:   newScheduler(sched),
+		bus:     cfg.EventBus,
+		now:     cfg.Now,
 		log:     log,
 	}, nil
 }
@@ -69,6 +160,15 @@ func (a *DefaultAgent) GetName() string {
 	return a.name
 }
 
+func (a *DefaultAgent) GetTags() []string {
+	return a.tags
+}
+
+// GetPersona returns the agent's persona, or nil if it has none
+func (a *DefaultAgent) GetPersona() *Persona {
+	return a.persona
+}
+
 func (a *DefaultAgent) GetState() map[string]interface{} {
 	a.mu.RLock()
 	defer a.mu.RUnlock()
@@ -80,37 +180,329 @@ func (a *DefaultAgent) Think(ctx context.Context) error {
 
 	a.log.Info("Agent thinking", "ID", a.id, "Name", a.name)
 
+	a.mu.Lock()
+	seen := len(a.observations)
+	thoughtType, reason := a.sched.next(a.observations, a.state)
 	thought := &Thought{
-		Content:   "Thinking...", // Replace with actual LLM response
-		Type:      "fast",        // Default to fast thought
-		Timestamp: time.Now(),
+		Type:         thoughtType,
+		Timestamp:    a.clock(),
+		Reason:       reason,
+		Observations: append([]string(nil), a.observations...),
+	}
+	a.mu.Unlock()
+
+	if thoughtType == ThoughtTypeSlow {
+		a.log.Info("Escalating to slow thinking", "reason", reason)
 	}
 
 	// Run the pre-thought plugins
-	for _, p := range a.plugins {
+	for _, p := range a.GetPlugins() {
 		err := p.PreThink(ctx, thought)
 		if err != nil {
 			return fmt.Errorf("plugin pre-thought error: %w", err)
 		}
 	}
 
-	// TODO: Format memories and input for LLM prompt
-	// TODO: Call LLM for thought generation
+	// Slow thoughts deliberate over why they were escalated, with room to
+	// reason it through
+	maxTokens, name := fastThoughtTokens, prompt.NameThought
+	if thought.Type == ThoughtTypeSlow {
+		maxTokens, name = slowThoughtTokens, prompt.NameDeliberation
+	}
+
+	tmpl, err := a.prompts.Get(name)
+	if err != nil {
+		return err
+	}
+	messages, err := a.buildPrompt(ctx, tmpl, thought, prompt.Data{Reason: thought.Reason}, maxTokens)
+	if err != nil {
+		return err
+	}
+	thought.PromptID = tmpl.ID()
+
+	resp, err := llm.StreamOrComplete(ctx, a.llm, llm.ChatRequest{
+		Model:     a.models[thought.Type],
+		Messages:  messages,
+		MaxTokens: maxTokens,
+		PromptID:  thought.PromptID,
+	}, a.publishThinking)
+	if err != nil {
+		return fmt.Errorf("thought generation failed: %w", err)
+	}
+	thought.Content = strings.TrimSpace(resp.Content)
+	thought.Timestamp = a.clock()
+
+	// Run the post-thought plugins
+	for _, p := range a.GetPlugins() {
+		err := p.PostThink(ctx, thought)
+		if err != nil {
+			return fmt.Errorf("plugin post-thought error: %w", err)
+		}
+	}
+
+	a.mu.Lock()
+	a.lastThought = thought
+	a.observations = a.observations[seen:]
+	a.mu.Unlock()
 
+	a.log.Debug("Agent thought", "type", thought.Type, "content", thought.Content)
+	a.publish(event.TypeAgentThought, map[string]interface{}{
+		"type":    thought.Type,
+		"reason":  reason,
+		"content": thought.Content,
+		"prompt":  thought.PromptID,
+	})
 	return nil
 }
 
+// LastThought returns the most recent thought, or nil before the first
+func (a *DefaultAgent) LastThought() *Thought {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.lastThought
+}
+
+// buildPrompt fills data with what the agent knows and renders tmpl. The
+// persona, state and world are always included, while observations and
+// memories are trimmed so that the prompt and a completion of maxTokens fit
+// the model's context window.
+func (a *DefaultAgent) buildPrompt(ctx context.Context, tmpl *prompt.Template, thought *Thought, data prompt.Data, maxTokens int) ([]llm.Message, error) {
+	data.Name = a.name
+	data.Time = thought.Timestamp
+
+	// Whatever the template renders without the packed parts is fixed cost
+	fixed, err := tmpl.Render(data)
+	if err != nil {
+		return nil, err
+	}
+	counter := contextwindow.HeuristicCounter{}
+	reserve := contextwindow.CountMessages(counter, fixed) + maxTokens
+
+	memories, observations := thought.Memories, thought.Observations
+	if thought.Type != ThoughtTypeSlow {
+		memories = memories[:min(len(memories), fastMemoryLimit)]
+		observations = observations[max(len(observations)-fastObservationLimit, 0):]
+	}
+
+	a.mu.RLock()
+	state := make(map[string]interface{}, len(a.state))
+	for k, v := range a.state {
+		state[k] = v
+	}
+	a.mu.RUnlock()
+
+	var world map[string]interface{}
+	if env := EnvironmentFrom(ctx); env != nil {
+		world = env.GetState()
+	}
+
+	var persona []string
+	if p := a.persona.Describe(); p != "" {
+		persona = []string{p}
+	}
+
+	segments := []contextwindow.Segment{
+		{Name: "persona", Priority: 4, Items: persona, Required: true},
+		{Name: "state", Priority: 3, Items: mapItems(state), Required: true},
+		{Name: "world", Priority: 3, Items: mapItems(world), Required: true},
+		{Name: "observations", Priority: 2, Items: observations, Order: contextwindow.KeepLast},
+		{Name: "memories", Priority: 1, Items: memories, Order: contextwindow.KeepFirst},
+	}
+	reserve += len(segments) * sectionTokens
+
+	packer := contextwindow.NewPacker(a.models[thought.Type], reserve)
+	packed, dropped := packer.Pack(segments)
+	if dropped > 0 {
+		a.log.Debug("Trimmed prompt to fit context window", "dropped", dropped)
+	}
+
+	data.Persona = strings.Join(packed[0].Items, "")
+	data.State = unpackMap(state, packed[1].Items)
+	data.World = unpackMap(world, packed[2].Items)
+	data.Observations = packed[3].Items
+	data.Memories = packed[4].Items
+	return tmpl.Render(data)
+}
+
+// mapItems renders a map as "key: value" items in key order, the way the
+// templates list it
+func mapItems(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	items := make([]string, len(keys))
+	for i, k := range keys {
+		items[i] = fmt.Sprintf("%s: %v", k, m[k])
+	}
+	return items
+}
+
+// unpackMap rebuilds a map from the items mapItems made of it, replacing the
+// values whose items the packer had to truncate
+func unpackMap(m map[string]interface{}, items []string) map[string]interface{} {
+	if len(m) == 0 {
+		return m
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	out := make(map[string]interface{}, len(items))
+	for i, item := range items {
+		k := keys[i]
+		if item == fmt.Sprintf("%s: %v", k, m[k]) {
+			out[k] = m[k]
+		} else {
+			out[k] = strings.TrimPrefix(item, k+": ")
+		}
+	}
+	return out
+}
+
+func (a *DefaultAgent) publishThinking(delta string) {
+	a.publish(event.TypeAgentThinking, map[string]interface{}{
+		"delta": delta,
+	})
+}
+
+func (a *DefaultAgent) publish(t event.Type, data map[string]interface{}) {
+	if a.bus == nil {
+		return
+	}
+	if err := a.bus.Publish(event.Event{
+		Type:      t,
+		Source:    a.id,
+		Timestamp: time.Now(),
+		Data:      data,
+	}); err != nil {
+		a.log.Warn("Failed to publish event", "type", t, "error", err)
+	}
+}
+
+// clock returns the simulation time, or the zero time without a clock
+func (a *DefaultAgent) clock() time.Time {
+	if a.now == nil {
+		return time.Time{}
+	}
+	return a.now()
+}
+
+// observe records something the agent noticed, to be considered in its
+// next thought
+func (a *DefaultAgent) observe(observation string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.observations = append(a.observations, observation)
+}
+
+// actionChoice is the structured answer expected when deciding an action
+type actionChoice struct {
+	Action string `json:"action" validate:"required" jsonschema:"description=One of the available actions"`
+	Target string `json:"target,omitempty" jsonschema:"description=ID of the agent or object the action is directed at, if any"`
+	Intent string `json:"intent" validate:"required" jsonschema:"description=Why you are doing this, in one sentence"`
+}
+
 func (a *DefaultAgent) DecideAction(ctx context.Context) (action.Action, error) {
-	// TODO: Implement action decision logic with LLM
 	a.log.Info("Agent deciding action", "ID", a.id, "Name", a.name)
-	return &action.SimpleAction{
-		Type: action.ActionTypeNoop,
-	}, nil
+
+	act, err := a.chooseAction(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Run the pre-action plugins
+	for _, p := range a.GetPlugins() {
+		err := p.PreAction(ctx, act)
+		if err != nil {
+			return nil, fmt.Errorf("plugin pre-action error: %w", err)
+		}
+	}
+	return act, nil
+}
+
+// chooseAction asks the LLM to pick one of the actions the world allows,
+// re-prompting when the world rejects the choice. It falls back to a no-op
+// if no valid action is found.
+func (a *DefaultAgent) chooseAction(ctx context.Context) (action.Action, error) {
+	noop := &action.SimpleAction{From: a.id, Type: action.ActionTypeNoop}
+
+	env := EnvironmentFrom(ctx)
+	if env == nil {
+		return noop, nil
+	}
+	allowed := env.AvailableActions(a.id)
+	// Nothing to choose between, so don't pay for a choice
+	if len(allowed) == 0 || len(allowed) == 1 && allowed[0] == action.ActionTypeNoop {
+		return noop, nil
+	}
+
+	tmpl, err := a.prompts.Get(prompt.NameAction)
+	if err != nil {
+		return nil, err
+	}
+
+	a.mu.RLock()
+	thought := &Thought{Type: ThoughtTypeFast, Timestamp: a.clock()}
+	if a.lastThought != nil {
+		thought = a.lastThought
+	}
+	a.mu.RUnlock()
+
+	messages, err := a.buildPrompt(ctx, tmpl, thought, prompt.Data{
+		Thought: thought.Content,
+		Actions: allowed,
+	}, actionTokens)
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 1; attempt <= maxActionAttempts; attempt++ {
+		choice, err := llm.Structured[actionChoice](ctx, a.llm, llm.ChatRequest{
+			Model:    a.models[thought.Type],
+			Messages: messages,
+			PromptID: tmpl.ID(),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("action selection failed: %w", err)
+		}
+
+		act := &action.SimpleAction{
+			From:              a.id,
+			To:                choice.Target,
+			Type:              choice.Action,
+			IntentDescription: choice.Intent,
+		}
+		if env.IsValidAction(act) {
+			return act, nil
+		}
+
+		a.log.Debug("World rejected action", "attempt", attempt, "action", choice.Action, "target", choice.Target)
+		a.mu.Lock()
+		a.sched.flagConflict()
+		a.mu.Unlock()
+		answer, _ := json.Marshal(choice)
+		messages = append(messages,
+			llm.Message{Role: llm.RoleAssistant, Content: string(answer)},
+			llm.Message{Role: llm.RoleUser, Content: fmt.Sprintf(
+				"You cannot do %q here. Choose again from: %s.", choice.Action, strings.Join(allowed, ", "))},
+		)
+	}
+
+	a.log.Warn("No valid action chosen, doing nothing", "attempts", maxActionAttempts)
+	return noop, nil
 }
 
 func (a *DefaultAgent) ReceiveOutcome(ctx context.Context, action action.Action, outcome string) error {
 	// Run postaction hooks
 	a.log.Info("Agent receiving outcome", "ID", a.id, "Name", a.name, "Action", action.GetType(), "Outcome", outcome)
+	if outcome != "" {
+		a.observe(fmt.Sprintf("You did %s: %s", action.GetType(), outcome))
+	}
 	for _, p := range a.plugins {
 		err := p.PostAction(ctx, action)
 		if err != nil {
@@ -158,6 +550,15 @@ func (a *DefaultAgent) ReceiveInteraction(ctx context.Context, source Agent, act
 		"source_id", source.GetID(),
 		"action_type", action.GetType(),
 	)
-	// TODO: Process received interaction
+
+	observation := fmt.Sprintf("%s did %s to you", source.GetName(), action.GetType())
+	if intent := action.Intent(); intent != "" {
+		observation += ": " + intent
+	}
+	a.observe(observation)
+
+	a.mu.Lock()
+	a.sched.flagConflict()
+	a.mu.Unlock()
 	return nil
 }
@@ -0,0 +1,111 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxSeenObservations bounds the memory the scheduler keeps for novelty
+// detection
+const maxSeenObservations = 1000
+
+// SchedulerConfig tunes when an agent escalates from fast to slow thinking.
+// Zero fields disable the corresponding trigger.
+type SchedulerConfig struct {
+	// NoveltyThreshold is the number of never before seen observations
+	// that triggers slow thinking
+	NoveltyThreshold int
+	// MaxFastStreak forces a slow thought after this many fast ones
+	MaxFastStreak int
+	// GoalKey is the state key holding the agent's goal. A change of goal
+	// triggers slow thinking.
+	GoalKey string
+	// Conflict escalates after the world rejected the agent's action or
+	// another agent acted on it
+	Conflict bool
+}
+
+// DefaultSchedulerConfig returns the scheduler used when none is configured
+func DefaultSchedulerConfig() SchedulerConfig {
+	return SchedulerConfig{
+		NoveltyThreshold: 2,
+		MaxFastStreak:    10,
+		GoalKey:          "goal",
+		Conflict:         true,
+	}
+}
+
+// scheduler implements dual-process thinking: cheap fast thoughts by default,
+// with escalation to slow thoughts when something warrants deliberation
+type scheduler struct {
+	cfg        SchedulerConfig
+	seen       map[string]struct{}
+	lastGoal   string
+	fastStreak int
+	conflict   bool
+}
+
+func newScheduler(cfg SchedulerConfig) *scheduler {
+	return &scheduler{
+		cfg:  cfg,
+		seen: make(map[string]struct{}),
+	}
+}
+
+// flagConflict records a conflict to be considered at the next thought
+func (s *scheduler) flagConflict() {
+	s.conflict = true
+}
+
+// next decides the type of the upcoming thought and why
+func (s *scheduler) next(observations []string, state map[string]interface{}) (string, string) {
+	reason := s.escalation(observations, state)
+	s.conflict = false
+
+	if reason == "" {
+		s.fastStreak++
+		return ThoughtTypeFast, ""
+	}
+	s.fastStreak = 0
+	return ThoughtTypeSlow, reason
+}
+
+func (s *scheduler) escalation(observations []string, state map[string]interface{}) string {
+	var reasons []string
+
+	if len(s.seen) > maxSeenObservations {
+		s.seen = make(map[string]struct{})
+	}
+	novel := 0
+	for _, o := range observations {
+		key := strings.ToLower(strings.TrimSpace(o))
+		if _, ok := s.seen[key]; !ok {
+			s.seen[key] = struct{}{}
+			novel++
+		}
+	}
+	if s.cfg.NoveltyThreshold > 0 && novel >= s.cfg.NoveltyThreshold {
+		reasons = append(reasons, "novelty")
+	}
+
+	if s.cfg.Conflict && s.conflict {
+		reasons = append(reasons, "conflict")
+	}
+
+	if s.cfg.GoalKey != "" {
+		goal := ""
+		if g, ok := state[s.cfg.GoalKey]; ok {
+			goal = fmt.Sprint(g)
+		}
+		if goal != s.lastGoal {
+			s.lastGoal = goal
+			reasons = append(reasons, "goal change")
+		}
+	}
+
+	if s.cfg.MaxFastStreak > 0 && s.fastStreak >= s.cfg.MaxFastStreak {
+		reasons = append(reasons, "periodic")
+	}
+
+	return strings.Join(reasons, ", ")
+}
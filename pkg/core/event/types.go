@@ -11,8 +11,12 @@ const (
 	TypeAgentJoined      Type = "agent_joined"
 	TypeAgentLeft        Type = "agent_left"
 	TypeAgentAction      Type = "agent_action"
+	TypeAgentThought     Type = "agent_thought"
+	TypeAgentThinking    Type = "agent_thinking" // partial thought while streaming
 	TypeWorldStateChange Type = "world_state_change"
 	TypeAgentInteraction Type = "agent_interaction"
+	TypeLLMUsage         Type = "llm_usage"
+	TypeBudgetExceeded   Type = "budget_exceeded"
 )
 
 // Event represents a basic event in the system
@@ -14,6 +14,7 @@ const (
 	CategoryTimeManager = "time_manager"
 	CategoryResearch    = "research"
 	CategoryPerf        = "performance"
+	CategoryLLM         = "llm"
 )
 
 // SetupLogger configures slog for our simulation
@@ -3,10 +3,15 @@ package simulation
 import (
 	"context"
 	"fmt"
+	"simulacra/pkg/core/action"
 	"simulacra/pkg/core/agent"
 	"simulacra/pkg/core/event"
 	"simulacra/pkg/core/world"
+	"simulacra/pkg/llm/budget"
+	"simulacra/pkg/llm/router"
+	"simulacra/pkg/llm/usage"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -24,11 +29,34 @@ type Simulation struct {
 	// Configuration
 	stepInterval time.Duration
 
+	// Run bookkeeping, attached to the context of every step
+	runID string
+	steps int
+
+	// Simulation clock in unix nanoseconds, read by agents mid-step
+	startTime time.Time
+	timeStep  time.Duration
+	clock     atomic.Int64
+
+	// Optional spending caps. PolicyPause is checked before and after each
+	// step, PolicyNoop per agent as the step runs.
+	budget *budget.Guard
+
 	mu sync.RWMutex
 }
 
 func New(w world.World, config Config) *Simulation {
-	return &Simulation{
+	runID := config.RunID
+	if runID == "" {
+		runID = time.Now().UTC().Format("20060102T150405Z")
+	}
+
+	timeStep := config.TimeStep
+	if timeStep == 0 {
+		timeStep = config.StepInterval
+	}
+
+	s := &Simulation{
 		world:        w,
 		eventBus:     event.NewEventBus(),
 		agents:       make(map[string]agent.Agent),
@@ -36,11 +64,29 @@ func New(w world.World, config Config) *Simulation {
 		pauseCh:      make(chan struct{}),
 		resumeCh:     make(chan struct{}),
 		stepInterval: config.StepInterval,
+		runID:        runID,
+		startTime:    config.StartTime,
+		timeStep:     timeStep,
+		budget:       config.Budget,
+	}
+	if !config.StartTime.IsZero() {
+		s.clock.Store(config.StartTime.UnixNano())
 	}
+	return s
 }
 
 type Config struct {
 	StepInterval time.Duration
+	// RunID identifies this run in usage accounting, defaults to the start time
+	RunID string
+	// Budget enforces PolicyPause and PolicyNoop. PolicyDowngrade is applied
+	// by wrapping the agents' provider with budget.NewProvider.
+	Budget *budget.Guard
+	// StartTime and TimeStep drive the simulation clock returned by Now,
+	// which advances by TimeStep each step. TimeStep defaults to
+	// StepInterval. A zero StartTime leaves the clock unset.
+	StartTime time.Time
+	TimeStep  time.Duration
 }
 
 // AddAgent adds an agent to the simulation
@@ -77,18 +123,52 @@ func (s *Simulation) Start(ctx context.Context) error {
 		case <-s.pauseCh:
 			<-s.resumeCh // Wait for resume signal
 		case <-ticker.C:
+			// Check before the step too, so resuming with the budget still
+			// exceeded pauses again instead of paying for one more step
+			if s.overBudget() {
+				if stop, err := s.awaitResume(ctx); stop {
+					return err
+				}
+				continue
+			}
 			if err := s.step(ctx); err != nil {
 				return fmt.Errorf("simulation step error: %w", err)
 			}
+			if s.overBudget() {
+				if stop, err := s.awaitResume(ctx); stop {
+					return err
+				}
+			}
 		}
 	}
 }
 
+// awaitResume blocks a simulation paused over budget until Resume, even if
+// the budget is still exceeded, so the caller can raise it first. It reports
+// whether the simulation should stop instead.
+func (s *Simulation) awaitResume(ctx context.Context) (bool, error) {
+	select {
+	case <-ctx.Done():
+		return true, ctx.Err()
+	case <-s.stopCh:
+		return true, nil
+	case <-s.resumeCh:
+		return false, nil
+	}
+}
+
 // step advances the simulation by one tick
 func (s *Simulation) step(ctx context.Context) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	s.steps++
+	if !s.startTime.IsZero() {
+		s.clock.Store(s.startTime.Add(time.Duration(s.steps) * s.timeStep).UnixNano())
+	}
+	ctx = usage.WithStep(usage.WithRun(ctx, s.runID), s.steps)
+	ctx = agent.WithEnvironment(ctx, s.world)
+
 	// 1. Process world state
 	_ = s.world.GetState()
 
@@ -100,16 +180,12 @@ func (s *Simulation) step(ctx context.Context) error {
 		wg.Add(1)
 		go func(agent agent.Agent) {
 			defer wg.Done()
+			ctx := usage.WithAgent(ctx, agent.GetID())
+			ctx = router.WithTags(ctx, agent.GetTags()...)
 
-			err := agent.Think(ctx)
+			action, err := s.decide(ctx, agent)
 			if err != nil {
-				errs <- fmt.Errorf("agent %s think error: %w", agent.GetID(), err)
-				return
-			}
-
-			action, err := agent.DecideAction(ctx)
-			if err != nil {
-				errs <- fmt.Errorf("agent %s action error: %w", agent.GetID(), err)
+				errs <- err
 				return
 			}
 			// Based on world state and action, decide outcome
@@ -149,6 +225,73 @@ func (s *Simulation) step(ctx context.Context) error {
 	return nil
 }
 
+// decide runs the agent's think/act cycle, or substitutes a no-op when the
+// agent is over budget under PolicyNoop
+func (s *Simulation) decide(ctx context.Context, a agent.Agent) (action.Action, error) {
+	if s.budget != nil && s.budget.Policy() == budget.PolicyNoop && s.budget.Exceeded(a.GetID(), s.runID) {
+		s.eventBus.Publish(event.Event{
+			Type:      event.TypeBudgetExceeded,
+			Source:    "simulation",
+			Target:    a.GetID(),
+			Timestamp: time.Now(),
+			Data: map[string]interface{}{
+				"policy": budget.PolicyNoop,
+			},
+		})
+		return &action.SimpleAction{
+			From: a.GetID(),
+			Type: action.ActionTypeNoop,
+		}, nil
+	}
+
+	if err := a.Think(ctx); err != nil {
+		return nil, fmt.Errorf("agent %s think error: %w", a.GetID(), err)
+	}
+
+	act, err := a.DecideAction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("agent %s action error: %w", a.GetID(), err)
+	}
+	return act, nil
+}
+
+// overBudget reports whether the run or any agent is over budget under
+// PolicyPause, publishing an event if so
+func (s *Simulation) overBudget() bool {
+	if s.budget == nil || s.budget.Policy() != budget.PolicyPause {
+		return false
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	exceeded := s.budget.RunExceeded(s.runID)
+	target := ""
+	for id := range s.agents {
+		if exceeded {
+			break
+		}
+		if s.budget.AgentExceeded(id) {
+			exceeded = true
+			target = id
+		}
+	}
+	if !exceeded {
+		return false
+	}
+
+	s.eventBus.Publish(event.Event{
+		Type:      event.TypeBudgetExceeded,
+		Source:    "simulation",
+		Target:    target,
+		Timestamp: time.Now(),
+		Data: map[string]interface{}{
+			"policy": budget.PolicyPause,
+		},
+	})
+	return true
+}
+
 // Stop halts the simulation
 func (s *Simulation) Stop() {
 	close(s.stopCh)
@@ -164,6 +307,28 @@ func (s *Simulation) Resume() {
 	s.resumeCh <- struct{}{}
 }
 
+// GetRunID returns the identifier of this run
+func (s *Simulation) GetRunID() string {
+	return s.runID
+}
+
+// GetStep returns the number of steps executed so far
+func (s *Simulation) GetStep() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.steps
+}
+
+// Now returns the simulation time, which depends only on the step count so
+// that replayed runs see the same times. It is the zero time when no
+// StartTime was configured. Pass it as agent.Config.Now.
+func (s *Simulation) Now() time.Time {
+	if s.startTime.IsZero() {
+		return time.Time{}
+	}
+	return time.Unix(0, s.clock.Load()).In(s.startTime.Location())
+}
+
 // GetEventBus returns the simulation's event bus
 func (s *Simulation) GetEventBus() event.Bus {
 	return s.eventBus
@@ -3,6 +3,7 @@ package world
 import (
 	"encoding/json"
 	"log/slog"
+	"simulacra/pkg/core/action"
 	"simulacra/pkg/core/logger"
 	"simulacra/pkg/core/store"
 	"sync"
@@ -11,18 +12,27 @@ import (
 )
 
 type defaultWorld struct {
-	state store.DefaultStoreType
-	mu    sync.RWMutex
-	log   *slog.Logger
+	state   store.DefaultStoreType
+	actions []string
+	mu      sync.RWMutex
+	log     *slog.Logger
 }
 
 func NewDefaultWorld(log *slog.Logger) *defaultWorld {
 	return &defaultWorld{
-		state: store.DefaultStore(),
-		log:   log.With(logger.CategoryKey, logger.CategoryWorld),
+		state:   store.DefaultStore(),
+		actions: []string{action.ActionTypeNoop},
+		log:     log.With(logger.CategoryKey, logger.CategoryWorld),
 	}
 }
 
+// RegisterActions adds action types agents may choose from
+func (w *defaultWorld) RegisterActions(types ...string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.actions = append(w.actions, types...)
+}
+
 func (w *defaultWorld) GetState() map[string]interface{} {
 	w.mu.RLock()
 	defer w.mu.RUnlock()
@@ -64,9 +74,26 @@ func (w *defaultWorld) SetState(state map[string]interface{}) error {
 	return nil
 }
 
-func (w *defaultWorld) IsValidAction(action interface{}) bool {
-	// TODO: Implement action validation logic
-	return true
+func (w *defaultWorld) AvailableActions(agentID string) []string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return append([]string(nil), w.actions...)
+}
+
+func (w *defaultWorld) IsValidAction(a interface{}) bool {
+	act, ok := a.(action.Action)
+	if !ok {
+		return false
+	}
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	for _, t := range w.actions {
+		if t == act.GetType() {
+			return true
+		}
+	}
+	return false
 }
 
 func (w *defaultWorld) ApplyAction(action interface{}) (string, error) {
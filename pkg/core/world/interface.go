@@ -7,6 +7,7 @@ type World interface {
 	SetState(state map[string]interface{}) error
 
 	// World-specific logic
+	AvailableActions(agentID string) []string
 	IsValidAction(action interface{}) bool
 	ApplyAction(action interface{}) (outcome string, err error)
 }
@@ -0,0 +1,143 @@
+package budget
+
+import (
+	"context"
+	"sync"
+
+	"simulacra/pkg/llm"
+	"simulacra/pkg/llm/usage"
+)
+
+// Policy decides what happens to an agent that has spent its budget
+type Policy string
+
+const (
+	// PolicyPause pauses the whole simulation until it is resumed
+	PolicyPause Policy = "pause"
+	// PolicyDowngrade switches the agent's calls to Config.FallbackModel
+	PolicyDowngrade Policy = "downgrade"
+	// PolicyNoop makes the agent do nothing instead of calling the LLM
+	PolicyNoop Policy = "noop"
+)
+
+// Limit caps tokens and dollars. Zero fields are unlimited.
+type Limit struct {
+	Tokens int
+	Cost   float64
+}
+
+func (l Limit) exceededBy(s usage.Summary) bool {
+	return (l.Tokens > 0 && s.TotalTokens >= l.Tokens) ||
+		(l.Cost > 0 && s.Cost >= l.Cost)
+}
+
+// Config sets the budgets for a run
+type Config struct {
+	Run Limit
+	// Agent is the default limit for every agent
+	Agent Limit
+	// Agents overrides Agent for specific agent IDs
+	Agents        map[string]Limit
+	Policy        Policy
+	FallbackModel string
+}
+
+// Guard checks ledger totals against configured budgets
+type Guard struct {
+	cfg    Config
+	ledger *usage.Ledger
+	mu     sync.RWMutex
+}
+
+func NewGuard(cfg Config, ledger *usage.Ledger) *Guard {
+	return &Guard{
+		cfg:    cfg,
+		ledger: ledger,
+	}
+}
+
+// SetConfig replaces the budgets, e.g. to raise them before resuming a
+// paused simulation
+func (g *Guard) SetConfig(cfg Config) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.cfg = cfg
+}
+
+func (g *Guard) Policy() Policy {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.cfg.Policy
+}
+
+// RunExceeded reports whether the run as a whole is over budget
+func (g *Guard) RunExceeded(runID string) bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.cfg.Run.exceededBy(g.ledger.Run(runID))
+}
+
+// AgentExceeded reports whether an agent is over its own budget
+func (g *Guard) AgentExceeded(agentID string) bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	limit, ok := g.cfg.Agents[agentID]
+	if !ok {
+		limit = g.cfg.Agent
+	}
+	return limit.exceededBy(g.ledger.Agent(agentID))
+}
+
+// Exceeded reports whether either the agent or its run is over budget
+func (g *Guard) Exceeded(agentID, runID string) bool {
+	return g.AgentExceeded(agentID) || g.RunExceeded(runID)
+}
+
+// Provider applies PolicyDowngrade by rewriting the model of calls made on
+// behalf of agents that are over budget
+type Provider struct {
+	provider llm.Provider
+	guard    *Guard
+}
+
+var _ llm.Provider = &Provider{}
+var _ llm.StreamingProvider = &Provider{}
+var _ llm.Embedder = &Provider{}
+
+func NewProvider(provider llm.Provider, guard *Guard) *Provider {
+	return &Provider{
+		provider: provider,
+		guard:    guard,
+	}
+}
+
+func (p *Provider) Name() string {
+	return p.provider.Name()
+}
+
+func (p *Provider) ChatCompletion(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	return p.provider.ChatCompletion(ctx, p.downgrade(ctx, req))
+}
+
+func (p *Provider) ChatCompletionStream(ctx context.Context, req llm.ChatRequest) (<-chan llm.StreamChunk, error) {
+	return llm.Stream(ctx, p.provider, p.downgrade(ctx, req))
+}
+
+func (p *Provider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return llm.Embed(ctx, p.provider, texts)
+}
+
+// downgrade switches the request to the fallback model when the calling
+// agent is over budget under PolicyDowngrade
+func (p *Provider) downgrade(ctx context.Context, req llm.ChatRequest) llm.ChatRequest {
+	p.guard.mu.RLock()
+	fallback := p.guard.cfg.FallbackModel
+	downgrade := p.guard.cfg.Policy == PolicyDowngrade && fallback != ""
+	p.guard.mu.RUnlock()
+
+	if downgrade && p.guard.Exceeded(usage.AgentFrom(ctx), usage.RunFrom(ctx)) {
+		req.Model = fallback
+	}
+	return req
+}
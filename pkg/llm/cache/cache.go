@@ -0,0 +1,246 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+
+	"simulacra/pkg/core/store"
+	"simulacra/pkg/llm"
+)
+
+// Config controls the response cache
+type Config struct {
+	// Store defaults to store.DefaultStore()
+	Store store.DefaultStoreType
+	// Namespace separates caches sharing a store, e.g. per experiment
+	Namespace string
+	// TTL expires entries; zero keeps them forever
+	TTL time.Duration
+
+	// Embedder enables near-duplicate matching. A cached response is reused
+	// when its prompt embedding has cosine similarity of at least
+	// SimilarityThreshold with the new prompt.
+	Embedder            llm.Embedder
+	SimilarityThreshold float64
+}
+
+type entry struct {
+	Response  llm.ChatResponse `json:"response"`
+	Embedding []float32        `json:"embedding,omitempty"`
+	// Settings fingerprints everything but the messages, so near-duplicate
+	// matches only reuse responses produced under the same settings
+	Settings  string    `json:"settings,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Provider caches chat completions in leveldb, keyed by model and request
+// hash, with optional embedding based matching of near-identical prompts
+type Provider struct {
+	provider llm.Provider
+	cfg      Config
+	db       store.DefaultStoreType
+}
+
+var _ llm.Provider = &Provider{}
+var _ llm.StreamingProvider = &Provider{}
+var _ llm.Embedder = &Provider{}
+
+func New(provider llm.Provider, cfg Config) (*Provider, error) {
+	db := cfg.Store
+	if db == nil {
+		db = store.DefaultStore()
+	}
+	if db == nil {
+		return nil, fmt.Errorf("cache store is not initialised")
+	}
+	return &Provider{
+		provider: provider,
+		cfg:      cfg,
+		db:       db,
+	}, nil
+}
+
+func (p *Provider) Name() string {
+	return p.provider.Name()
+}
+
+func (p *Provider) ChatCompletion(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	key, embedding, resp := p.get(ctx, req)
+	if resp != nil {
+		return resp, nil
+	}
+
+	resp, err := p.provider.ChatCompletion(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	p.put(key, req, embedding, resp)
+	return resp, nil
+}
+
+// ChatCompletionStream serves hits as a single chunk, and caches misses once
+// their stream completes
+func (p *Provider) ChatCompletionStream(ctx context.Context, req llm.ChatRequest) (<-chan llm.StreamChunk, error) {
+	key, embedding, resp := p.get(ctx, req)
+	if resp != nil {
+		return llm.StreamResponse(resp), nil
+	}
+
+	chunks, err := llm.Stream(ctx, p.provider, req)
+	if err != nil {
+		return nil, err
+	}
+	return llm.ForwardStream(ctx, chunks, func(resp *llm.ChatResponse, err error) {
+		if resp != nil {
+			p.put(key, req, embedding, resp)
+		}
+	}), nil
+}
+
+func (p *Provider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return llm.Embed(ctx, p.provider, texts)
+}
+
+// get looks req up by exact hash, then by prompt similarity. On a miss it
+// returns the key and embedding to store the eventual response under.
+func (p *Provider) get(ctx context.Context, req llm.ChatRequest) ([]byte, []float32, *llm.ChatResponse) {
+	key := p.key(req.Model, hashRequest(req))
+	if resp, ok := p.lookup(key); ok {
+		return key, nil, resp
+	}
+
+	var embedding []float32
+	if p.cfg.Embedder != nil && p.cfg.SimilarityThreshold > 0 {
+		embeddings, err := p.cfg.Embedder.Embed(ctx, []string{promptText(req)})
+		if err == nil && len(embeddings) == 1 {
+			embedding = embeddings[0]
+			if resp, ok := p.nearest(req, embedding); ok {
+				return key, embedding, resp
+			}
+		}
+	}
+	return key, embedding, nil
+}
+
+func (p *Provider) put(key []byte, req llm.ChatRequest, embedding []float32, resp *llm.ChatResponse) {
+	b, err := json.Marshal(entry{
+		Response:  *resp,
+		Embedding: embedding,
+		Settings:  settingsHash(req),
+		CreatedAt: time.Now(),
+	})
+	if err == nil {
+		_ = p.db.Put(key, b, nil)
+	}
+}
+
+// Purge removes every entry in this cache's namespace
+func (p *Provider) Purge() error {
+	iter := p.db.NewIterator(util.BytesPrefix(p.namespacePrefix()), nil)
+	defer iter.Release()
+
+	batch := new(leveldb.Batch)
+	for iter.Next() {
+		batch.Delete(append([]byte(nil), iter.Key()...))
+	}
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	return p.db.Write(batch, nil)
+}
+
+func (p *Provider) lookup(key []byte) (*llm.ChatResponse, bool) {
+	b, err := p.db.Get(key, nil)
+	if err != nil {
+		return nil, false
+	}
+	var e entry
+	if json.Unmarshal(b, &e) != nil || p.expired(e) {
+		return nil, false
+	}
+	return &e.Response, true
+}
+
+// nearest scans the model's namespace for the most similar cached prompt
+// made with the same settings as req
+func (p *Provider) nearest(req llm.ChatRequest, embedding []float32) (*llm.ChatResponse, bool) {
+	iter := p.db.NewIterator(util.BytesPrefix(p.modelPrefix(req.Model)), nil)
+	defer iter.Release()
+
+	settings := settingsHash(req)
+	var best *llm.ChatResponse
+	bestScore := p.cfg.SimilarityThreshold
+	for iter.Next() {
+		var e entry
+		if json.Unmarshal(iter.Value(), &e) != nil || len(e.Embedding) == 0 || p.expired(e) {
+			continue
+		}
+		if e.Settings != settings {
+			continue
+		}
+		if score := llm.CosineSimilarity(embedding, e.Embedding); score >= bestScore {
+			resp := e.Response
+			best, bestScore = &resp, score
+		}
+	}
+	return best, best != nil
+}
+
+func (p *Provider) expired(e entry) bool {
+	return p.cfg.TTL > 0 && time.Since(e.CreatedAt) > p.cfg.TTL
+}
+
+func (p *Provider) namespacePrefix() []byte {
+	return []byte(fmt.Sprintf("%s/%s/", KeyPrefix, p.cfg.Namespace))
+}
+
+func (p *Provider) modelPrefix(model string) []byte {
+	// Escape so that models like "openai/gpt-4o" don't nest under "openai"
+	return append(p.namespacePrefix(), url.PathEscape(model)+"/"...)
+}
+
+func (p *Provider) key(model, hash string) []byte {
+	return append(p.modelPrefix(model), hash...)
+}
+
+// hashRequest hashes every field sent to the provider. Unlike
+// llm.HashRequest it includes sampling settings, tool choice and options,
+// since a response made under one of them is wrong for another.
+func hashRequest(req llm.ChatRequest) string {
+	req.PromptID = ""
+	return hashJSON(req)
+}
+
+// settingsHash hashes everything hashRequest covers except the messages
+func settingsHash(req llm.ChatRequest) string {
+	req.PromptID = ""
+	req.Messages = nil
+	return hashJSON(req)
+}
+
+func hashJSON(v interface{}) string {
+	// Maps marshal with sorted keys, so Options hash stably
+	b, _ := json.Marshal(v)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func promptText(req llm.ChatRequest) string {
+	var sb strings.Builder
+	for _, m := range req.Messages {
+		sb.WriteString(m.Role)
+		sb.WriteString(": ")
+		sb.WriteString(m.Content)
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
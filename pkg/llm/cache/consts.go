@@ -0,0 +1,5 @@
+package cache
+
+const (
+	KeyPrefix = "llm-cache"
+)
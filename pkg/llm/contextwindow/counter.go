@@ -0,0 +1,130 @@
+package contextwindow
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"simulacra/pkg/llm"
+)
+
+// messageOverhead approximates the tokens chat formats spend on role markers
+// and separators around each message
+const messageOverhead = 4
+
+// DefaultLimit is assumed for models missing from Limits
+const DefaultLimit = 8192
+
+// Limits maps model names to their context window in tokens. Callers may add
+// or override entries at start up.
+var Limits = map[string]int{
+	"gpt-4o":                           128000,
+	"gpt-4o-mini":                      128000,
+	"openai/gpt-4o":                    128000,
+	"openai/gpt-4o-mini":               128000,
+	"anthropic/claude-3.5-sonnet":      200000,
+	"anthropic/claude-3-haiku":         200000,
+	"meta-llama/llama-3.1-8b-instruct": 131072,
+	"llama3":                           8192,
+	"llama3.1":                         131072,
+	"mistral":                          32768,
+}
+
+// LimitFor returns the context window of a model
+func LimitFor(model string) int {
+	if limit, ok := Limits[model]; ok {
+		return limit
+	}
+	return DefaultLimit
+}
+
+// Counter counts the tokens in a piece of text. Implement it with a real
+// tokenizer when exact counts matter.
+type Counter interface {
+	Count(text string) int
+}
+
+// HeuristicCounter estimates tokens without a tokenizer. It assumes about
+// four characters per token for Latin text, one token per rune otherwise,
+// and never less than one token per word, which errs on the side of
+// overestimating.
+type HeuristicCounter struct{}
+
+func (HeuristicCounter) Count(text string) int {
+	if text == "" {
+		return 0
+	}
+
+	ascii, other, words := 0, 0, 0
+	inWord := false
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+		if unicode.IsSpace(r) {
+			inWord = false
+		} else if !inWord {
+			inWord = true
+			words++
+		}
+	}
+
+	estimate := (ascii+3)/4 + other
+	return max(estimate, words)
+}
+
+// CountMessages counts the tokens of a whole conversation
+func CountMessages(counter Counter, messages []llm.Message) int {
+	total := 0
+	for _, m := range messages {
+		total += countMessage(counter, m)
+	}
+	return total
+}
+
+func countMessage(counter Counter, m llm.Message) int {
+	n := messageOverhead + counter.Count(m.Content)
+	for _, c := range m.ToolCalls {
+		n += counter.Count(c.Name) + counter.Count(c.Arguments)
+	}
+	return n
+}
+
+// Truncate drops the oldest non-system messages until the conversation fits
+// in limit tokens. System messages and the final message are always kept.
+func Truncate(counter Counter, messages []llm.Message, limit int) []llm.Message {
+	total := CountMessages(counter, messages)
+	if total <= limit {
+		return messages
+	}
+
+	out := make([]llm.Message, 0, len(messages))
+	for i, m := range messages {
+		if total > limit && m.Role != llm.RoleSystem && i != len(messages)-1 {
+			total -= countMessage(counter, m)
+			continue
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// TruncateText cuts text down to roughly limit tokens, on a word boundary
+func TruncateText(counter Counter, text string, limit int) string {
+	if counter.Count(text) <= limit {
+		return text
+	}
+	words := strings.Fields(text)
+	lo, hi := 0, len(words)
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if counter.Count(strings.Join(words[:mid], " ")) <= limit {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return strings.Join(words[:lo], " ")
+}
@@ -0,0 +1,105 @@
+package contextwindow
+
+import "sort"
+
+// Order says which end of a segment's items is kept when it must be trimmed
+type Order int
+
+const (
+	// KeepFirst keeps the leading items, e.g. memories ranked by relevance
+	KeepFirst Order = iota
+	// KeepLast keeps the trailing items, e.g. the most recent observations
+	KeepLast
+)
+
+// Segment is a named part of a prompt competing for space
+type Segment struct {
+	Name string
+	// Higher priority segments are filled first
+	Priority int
+	Items    []string
+	Order    Order
+	// Required segments are always included, truncating the text of their
+	// items if nothing else fits
+	Required bool
+}
+
+// Packer fits prompt segments into a model's context window
+type Packer struct {
+	Counter Counter
+	// Limit is the context window in tokens
+	Limit int
+	// Reserve is kept free for the completion and fixed template text
+	Reserve int
+}
+
+// NewPacker returns a packer for model using the heuristic counter
+func NewPacker(model string, reserve int) *Packer {
+	return &Packer{
+		Counter: HeuristicCounter{},
+		Limit:   LimitFor(model),
+		Reserve: reserve,
+	}
+}
+
+// Pack returns the segments, in their original order, with items dropped
+// from lower priority segments until everything fits. It also reports how
+// many items were dropped.
+func (p *Packer) Pack(segments []Segment) ([]Segment, int) {
+	budget := p.Limit - p.Reserve
+
+	order := make([]int, len(segments))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		sa, sb := segments[order[a]], segments[order[b]]
+		if sa.Required != sb.Required {
+			return sa.Required
+		}
+		return sa.Priority > sb.Priority
+	})
+
+	packed := make([]Segment, len(segments))
+	dropped := 0
+	for _, idx := range order {
+		seg := segments[idx]
+		kept := p.fit(seg, &budget)
+		dropped += len(seg.Items) - len(kept)
+		seg.Items = kept
+		packed[idx] = seg
+	}
+	return packed, dropped
+}
+
+func (p *Packer) fit(seg Segment, budget *int) []string {
+	n := len(seg.Items)
+	item := func(i int) string {
+		if seg.Order == KeepLast {
+			return seg.Items[n-1-i]
+		}
+		return seg.Items[i]
+	}
+
+	var kept []string
+	for i := 0; i < n; i++ {
+		text := item(i)
+		cost := p.Counter.Count(text) + 1
+		if cost > *budget {
+			if !seg.Required {
+				break
+			}
+			text = TruncateText(p.Counter, text, max(*budget-1, 0))
+			cost = p.Counter.Count(text) + 1
+		}
+		*budget -= cost
+		kept = append(kept, text)
+	}
+
+	if seg.Order == KeepLast {
+		for i, j := 0, len(kept)-1; i < j; i, j = i+1, j-1 {
+			kept[i], kept[j] = kept[j], kept[i]
+		}
+	}
+	return kept
+}
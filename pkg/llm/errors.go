@@ -0,0 +1,50 @@
+package llm
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// APIError is returned by providers when the backend answers with an error
+// status. It lets callers such as the retry decorator classify failures
+// without knowing which provider produced them.
+type APIError struct {
+	StatusCode int
+	Message    string
+	// RetryAfter is the server requested delay before retrying, if any
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *APIError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("status %d: %s", e.StatusCode, e.Message)
+	}
+	if e.Err != nil {
+		return fmt.Sprintf("status %d: %v", e.StatusCode, e.Err)
+	}
+	return fmt.Sprintf("status %d", e.StatusCode)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// ParseRetryAfter parses a Retry-After header given either in seconds or as
+// an HTTP date. It returns 0 if the header is empty or invalid.
+func ParseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
@@ -4,21 +4,147 @@ import (
 	"fmt"
 
 	"simulacra/pkg/llm"
+	"simulacra/pkg/llm/hashembed"
+	"simulacra/pkg/llm/ollama"
+	"simulacra/pkg/llm/openaicompat"
 	"simulacra/pkg/llm/openrouter"
+	"simulacra/pkg/llm/ratelimit"
+	"simulacra/pkg/llm/recorder"
+	"simulacra/pkg/llm/retry"
+	"simulacra/pkg/llm/router"
+	"simulacra/pkg/llm/scripted"
 )
 
 // Config holds the configuration for LLM providers
 type Config struct {
 	Provider string
 	APIKey   string
+
+	// Settings for OpenAI-compatible endpoints, also honored by openrouter
+	BaseURL      string
+	Organization string
+	Headers      map[string]string
+	Model        string
+
+	// EmbeddingModel is used by providers that implement llm.Embedder
+	EmbeddingModel string
+
+	// Ollama specific settings
+	KeepAlive string
+	Options   map[string]interface{}
+
+	// ScriptPath is the JSONL script or cassette used by the scripted provider
+	ScriptPath string
+
+	// Fallbacks are tried in order when the primary provider fails. Only
+	// their provider settings are used; wrappers are configured here.
+	Fallbacks []Config
+
+	// RateLimit caps requests, tokens and concurrency when set
+	RateLimit *ratelimit.Config
+
+	// Retry wraps the provider with a retry policy when set
+	Retry *retry.Config
+
+	// RecordMode wraps the provider in a recorder writing to or reading from
+	// CassettePath. Leave empty to disable recording.
+	RecordMode   recorder.Mode
+	CassettePath string
 }
 
 // New creates a new LLM provider based on the configuration
 func New(config Config) (llm.Provider, error) {
+	provider, err := newProvider(config)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(config.Fallbacks) > 0 {
+		routes := []router.Route{{Name: config.Provider, Provider: provider}}
+		for _, fc := range config.Fallbacks {
+			fallback, err := newProvider(fc)
+			if err != nil {
+				return nil, fmt.Errorf("fallback %s: %w", fc.Provider, err)
+			}
+			// Fallbacks usually serve a different model than the primary
+			routes = append(routes, router.Route{Name: fc.Provider, Provider: fallback, Model: fc.Model})
+		}
+		provider, err = router.New(router.Config{Routes: routes, Strategy: router.StrategyOrdered})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if config.Retry != nil {
+		provider = retry.New(provider, *config.Retry)
+	}
+
+	// Limit outside the retry loop so time spent queueing for a slot does not
+	// count against retry's per-call timeout. Retries keep the slot of the
+	// call they repeat and are paced by retry's backoff.
+	if config.RateLimit != nil {
+		provider = ratelimit.New(provider, *config.RateLimit)
+	}
+
+	if config.RecordMode == "" {
+		return provider, nil
+	}
+	if config.CassettePath == "" {
+		return nil, fmt.Errorf("cassette path is required when recording")
+	}
+	return recorder.New(config.RecordMode, provider, config.CassettePath)
+}
+
+// NewEmbedder creates an embedder based on the configuration. The "hashing"
+// provider returns a deterministic local embedder that needs no network.
+func NewEmbedder(config Config) (llm.Embedder, error) {
+	if config.Provider == "hashing" {
+		return hashembed.New(hashembed.DefaultDimensions), nil
+	}
+
+	provider, err := newProvider(config)
+	if err != nil {
+		return nil, err
+	}
+	embedder, ok := provider.(llm.Embedder)
+	if !ok {
+		return nil, fmt.Errorf("provider %s does not support embeddings", config.Provider)
+	}
+	return embedder, nil
+}
+
+func newProvider(config Config) (llm.Provider, error) {
 	switch config.Provider {
 	case "openrouter":
-		return openrouter.New(config.APIKey), nil
+		return openrouter.NewWithConfig(compatConfig(config)), nil
+	case "openai":
+		// An empty BaseURL targets api.openai.com
+		return openaicompat.New(compatConfig(config)), nil
+	case "ollama":
+		return ollama.New(ollama.Config{
+			BaseURL:        config.BaseURL,
+			DefaultModel:   config.Model,
+			EmbeddingModel: config.EmbeddingModel,
+			KeepAlive:      config.KeepAlive,
+			Options:        config.Options,
+		}), nil
+	case "scripted":
+		if config.ScriptPath == "" {
+			return nil, fmt.Errorf("script path is required for scripted provider")
+		}
+		return scripted.Load(config.ScriptPath)
 	default:
 		return nil, fmt.Errorf("unsupported provider: %s", config.Provider)
 	}
 }
+
+func compatConfig(config Config) openaicompat.Config {
+	return openaicompat.Config{
+		APIKey:         config.APIKey,
+		BaseURL:        config.BaseURL,
+		Organization:   config.Organization,
+		Headers:        config.Headers,
+		DefaultModel:   config.Model,
+		EmbeddingModel: config.EmbeddingModel,
+	}
+}
@@ -0,0 +1,32 @@
+package llm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// HashRequest returns a stable hash of the model, messages and tools of a
+// request.
+// It is used to key recorded responses for deterministic replay.
+func HashRequest(req ChatRequest) string {
+	b, _ := json.Marshal(struct {
+		Model    string    `json:"model"`
+		Messages []Message `json:"messages"`
+		Tools    []Tool    `json:"tools,omitempty"`
+	}{
+		Model:    req.Model,
+		Messages: req.Messages,
+		Tools:    req.Tools,
+	})
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// HashTexts returns a stable hash of the texts of an embedding request. It
+// keys recorded embeddings the way HashRequest keys chat responses.
+func HashTexts(texts []string) string {
+	b, _ := json.Marshal(texts)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,80 @@
+package hashembed
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+
+	"simulacra/pkg/llm"
+)
+
+const DefaultDimensions = 256
+
+// Embedder produces deterministic embeddings by hashing word unigrams and
+// bigrams into a fixed number of buckets (the "hashing trick"). It needs no
+// network access and gives the same vector for the same text on every run,
+// which makes it suitable for offline runs and replayed simulations.
+type Embedder struct {
+	dimensions int
+}
+
+var _ llm.Embedder = &Embedder{}
+
+func New(dimensions int) *Embedder {
+	if dimensions <= 0 {
+		dimensions = DefaultDimensions
+	}
+	return &Embedder{dimensions: dimensions}
+}
+
+func (e *Embedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	embeddings := make([][]float32, len(texts))
+	for i, text := range texts {
+		embeddings[i] = e.embed(text)
+	}
+	return embeddings, nil
+}
+
+func (e *Embedder) embed(text string) []float32 {
+	vec := make([]float32, e.dimensions)
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	for i, w := range words {
+		e.add(vec, w)
+		if i > 0 {
+			e.add(vec, words[i-1]+" "+w)
+		}
+	}
+
+	// L2-normalise so cosine similarity reduces to a dot product
+	var norm float64
+	for _, v := range vec {
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		return vec
+	}
+	scale := float32(1 / math.Sqrt(norm))
+	for i := range vec {
+		vec[i] *= scale
+	}
+	return vec
+}
+
+func (e *Embedder) add(vec []float32, token string) {
+	h := fnv.New64a()
+	h.Write([]byte(token))
+	sum := h.Sum64()
+
+	// The top bit picks the sign so that collisions tend to cancel out
+	idx := int(sum % uint64(e.dimensions))
+	if sum>>63 == 1 {
+		vec[idx]--
+	} else {
+		vec[idx]++
+	}
+}
@@ -0,0 +1,254 @@
+package ollama
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"simulacra/pkg/llm"
+)
+
+const defaultAPIEndpoint = "http://localhost:11434"
+
+// Config configures a client for Ollama's native API
+type Config struct {
+	BaseURL        string
+	DefaultModel   string
+	EmbeddingModel string
+	// KeepAlive controls how long the model stays loaded, e.g. "10m" or "-1"
+	KeepAlive string
+	// Options are sent with every request; per-request ChatRequest.Options
+	// take precedence
+	Options    map[string]interface{}
+	HTTPClient *http.Client
+}
+
+// Provider speaks Ollama's /api/chat and /api/embeddings endpoints
+type Provider struct {
+	baseURL        string
+	defaultModel   string
+	embeddingModel string
+	keepAlive      string
+	options        map[string]interface{}
+	client         *http.Client
+}
+
+var _ llm.Provider = &Provider{}
+var _ llm.Embedder = &Provider{}
+
+func New(cfg Config) *Provider {
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		baseURL = defaultAPIEndpoint
+	}
+	client := cfg.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Provider{
+		baseURL:        strings.TrimRight(baseURL, "/"),
+		defaultModel:   cfg.DefaultModel,
+		embeddingModel: cfg.EmbeddingModel,
+		keepAlive:      cfg.KeepAlive,
+		options:        cfg.Options,
+		client:         client,
+	}
+}
+
+func (p *Provider) Name() string {
+	return "ollama"
+}
+
+type chatMessage struct {
+	Role      string         `json:"role"`
+	Content   string         `json:"content"`
+	ToolCalls []chatToolCall `json:"tool_calls,omitempty"`
+}
+
+type chatToolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+type chatTool struct {
+	Type     string   `json:"type"`
+	Function llm.Tool `json:"function"`
+}
+
+type chatRequest struct {
+	Model     string                 `json:"model"`
+	Messages  []chatMessage          `json:"messages"`
+	Tools     []chatTool             `json:"tools,omitempty"`
+	Stream    bool                   `json:"stream"`
+	Options   map[string]interface{} `json:"options,omitempty"`
+	KeepAlive string                 `json:"keep_alive,omitempty"`
+}
+
+type chatResponse struct {
+	Model           string      `json:"model"`
+	Message         chatMessage `json:"message"`
+	PromptEvalCount int         `json:"prompt_eval_count"`
+	EvalCount       int         `json:"eval_count"`
+}
+
+func (p *Provider) ChatCompletion(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	messages := make([]chatMessage, len(req.Messages))
+	for i, msg := range req.Messages {
+		messages[i] = chatMessage{
+			Role:    msg.Role,
+			Content: msg.Content,
+		}
+		for _, c := range msg.ToolCalls {
+			var call chatToolCall
+			call.Function.Name = c.Name
+			call.Function.Arguments = json.RawMessage(c.Arguments)
+			if c.Arguments == "" {
+				call.Function.Arguments = json.RawMessage("{}")
+			}
+			messages[i].ToolCalls = append(messages[i].ToolCalls, call)
+		}
+	}
+
+	// Ollama has no tool_choice; tools are always offered as "auto"
+	var tools []chatTool
+	if req.ToolChoice != llm.ToolChoiceNone {
+		for _, t := range req.Tools {
+			tools = append(tools, chatTool{Type: "function", Function: t})
+		}
+	}
+
+	options := p.mergeOptions(req.Options)
+	if req.Temperature != 0 {
+		options["temperature"] = req.Temperature
+	}
+	if req.MaxTokens > 0 {
+		options["num_predict"] = req.MaxTokens
+	}
+
+	model := p.model(req.Model, p.defaultModel)
+	var resp chatResponse
+	err := p.post(ctx, "/api/chat", chatRequest{
+		Model:     model,
+		Messages:  messages,
+		Tools:     tools,
+		Options:   options,
+		KeepAlive: p.keepAlive,
+	}, &resp)
+	if err != nil {
+		return nil, fmt.Errorf("ollama chat completion failed: %w", err)
+	}
+
+	var toolCalls []llm.ToolCall
+	for _, c := range resp.Message.ToolCalls {
+		toolCalls = append(toolCalls, llm.ToolCall{
+			Name:      c.Function.Name,
+			Arguments: string(c.Function.Arguments),
+		})
+	}
+
+	if resp.Model != "" {
+		model = resp.Model
+	}
+	return &llm.ChatResponse{
+		Content:   resp.Message.Content,
+		ToolCalls: toolCalls,
+		Usage: llm.TokenUsage{
+			PromptTokens:     resp.PromptEvalCount,
+			CompletionTokens: resp.EvalCount,
+			TotalTokens:      resp.PromptEvalCount + resp.EvalCount,
+		},
+		Model: model,
+	}, nil
+}
+
+type embeddingRequest struct {
+	Model     string                 `json:"model"`
+	Prompt    string                 `json:"prompt"`
+	Options   map[string]interface{} `json:"options,omitempty"`
+	KeepAlive string                 `json:"keep_alive,omitempty"`
+}
+
+type embeddingResponse struct {
+	Embedding []float32 `json:"embedding"`
+}
+
+// Embed returns one embedding per input text, using the configured
+// embedding model
+func (p *Provider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	model := p.model(p.embeddingModel, p.defaultModel)
+	embeddings := make([][]float32, len(texts))
+	for i, text := range texts {
+		var resp embeddingResponse
+		err := p.post(ctx, "/api/embeddings", embeddingRequest{
+			Model:     model,
+			Prompt:    text,
+			Options:   p.options,
+			KeepAlive: p.keepAlive,
+		}, &resp)
+		if err != nil {
+			return nil, fmt.Errorf("ollama embeddings failed: %w", err)
+		}
+		embeddings[i] = resp.Embedding
+	}
+	return embeddings, nil
+}
+
+func (p *Provider) post(ctx context.Context, path string, body, out interface{}) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+path, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	httpResp, err := p.client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		raw, _ := io.ReadAll(httpResp.Body)
+		if json.Unmarshal(raw, &apiErr) != nil || apiErr.Error == "" {
+			apiErr.Error = strings.TrimSpace(string(raw))
+		}
+		return &llm.APIError{
+			StatusCode: httpResp.StatusCode,
+			Message:    apiErr.Error,
+			RetryAfter: llm.ParseRetryAfter(httpResp.Header.Get("Retry-After")),
+		}
+	}
+
+	return json.NewDecoder(httpResp.Body).Decode(out)
+}
+
+func (p *Provider) mergeOptions(opts map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(p.options)+len(opts)+2)
+	for k, v := range p.options {
+		merged[k] = v
+	}
+	for k, v := range opts {
+		merged[k] = v
+	}
+	return merged
+}
+
+func (p *Provider) model(model, fallback string) string {
+	if model == "" {
+		return fallback
+	}
+	return model
+}
@@ -0,0 +1,362 @@
+package openaicompat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+
+	"simulacra/pkg/llm"
+)
+
+// Config configures a client for an OpenAI-compatible endpoint
+type Config struct {
+	// Name is reported by Provider.Name, defaults to "openai"
+	Name         string
+	APIKey       string
+	BaseURL      string
+	Organization string
+	// Headers are added to every request, e.g. HTTP-Referer for openrouter
+	Headers map[string]string
+	// DefaultModel is used when a request does not set a model
+	DefaultModel string
+	// EmbeddingModel is used by Embed
+	EmbeddingModel string
+}
+
+// Provider talks to any server implementing the OpenAI chat API, such as
+// llama.cpp server, vLLM, Ollama's OpenAI shim or a local stub.
+type Provider struct {
+	name           string
+	defaultModel   string
+	embeddingModel string
+	client         *openai.Client
+}
+
+var _ llm.Provider = &Provider{}
+var _ llm.Embedder = &Provider{}
+var _ llm.StreamingProvider = &Provider{}
+
+func New(cfg Config) *Provider {
+	config := openai.DefaultConfig(cfg.APIKey)
+	if cfg.BaseURL != "" {
+		config.BaseURL = cfg.BaseURL
+	}
+	config.OrgID = cfg.Organization
+	config.HTTPClient = &http.Client{
+		Transport: &transport{headers: cfg.Headers, base: http.DefaultTransport},
+	}
+
+	name := cfg.Name
+	if name == "" {
+		name = "openai"
+	}
+
+	return &Provider{
+		name:           name,
+		defaultModel:   cfg.DefaultModel,
+		embeddingModel: cfg.EmbeddingModel,
+		client:         openai.NewClientWithConfig(config),
+	}
+}
+
+func (p *Provider) Name() string {
+	return p.name
+}
+
+func (p *Provider) ChatCompletion(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	request := p.toRequest(req)
+	resp, err := p.client.CreateChatCompletion(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("%s chat completion failed: %w", p.name, toAPIError(err))
+	}
+
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("no choices in response")
+	}
+
+	msg := resp.Choices[0].Message
+	return &llm.ChatResponse{
+		Content:   msg.Content,
+		ToolCalls: fromToolCalls(msg.ToolCalls),
+		Usage: llm.TokenUsage{
+			PromptTokens:     resp.Usage.PromptTokens,
+			CompletionTokens: resp.Usage.CompletionTokens,
+			TotalTokens:      resp.Usage.TotalTokens,
+		},
+		Model: servedModel(resp.Model, request.Model),
+	}, nil
+}
+
+func (p *Provider) ChatCompletionStream(ctx context.Context, req llm.ChatRequest) (<-chan llm.StreamChunk, error) {
+	request := p.toRequest(req)
+	request.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
+
+	stream, err := p.client.CreateChatCompletionStream(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("%s chat completion stream failed: %w", p.name, toAPIError(err))
+	}
+
+	chunks := make(chan llm.StreamChunk)
+	go func() {
+		defer close(chunks)
+		defer stream.Close()
+
+		send := func(chunk llm.StreamChunk) bool {
+			select {
+			case chunks <- chunk:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		var content strings.Builder
+		var toolCalls []llm.ToolCall
+		var usage llm.TokenUsage
+		model := request.Model
+		for {
+			resp, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				send(llm.StreamChunk{Err: fmt.Errorf("%s chat completion stream failed: %w", p.name, err)})
+				return
+			}
+
+			model = servedModel(resp.Model, model)
+			if resp.Usage != nil {
+				usage = llm.TokenUsage{
+					PromptTokens:     resp.Usage.PromptTokens,
+					CompletionTokens: resp.Usage.CompletionTokens,
+					TotalTokens:      resp.Usage.TotalTokens,
+				}
+			}
+			if len(resp.Choices) == 0 {
+				continue
+			}
+
+			delta := resp.Choices[0].Delta
+			toolCalls = mergeToolCallDeltas(toolCalls, delta.ToolCalls)
+			if delta.Content == "" {
+				continue
+			}
+			content.WriteString(delta.Content)
+			if !send(llm.StreamChunk{Delta: delta.Content}) {
+				return
+			}
+		}
+
+		send(llm.StreamChunk{
+			Done: true,
+			Response: &llm.ChatResponse{
+				Content:   content.String(),
+				ToolCalls: toolCalls,
+				Usage:     usage,
+				Model:     model,
+			},
+		})
+	}()
+
+	return chunks, nil
+}
+
+// mergeToolCallDeltas accumulates streamed tool call fragments, which arrive
+// keyed by index with the arguments split across many chunks
+func mergeToolCallDeltas(calls []llm.ToolCall, deltas []openai.ToolCall) []llm.ToolCall {
+	for _, d := range deltas {
+		idx := len(calls)
+		if d.Index != nil {
+			idx = *d.Index
+		}
+		for len(calls) <= idx {
+			calls = append(calls, llm.ToolCall{})
+		}
+		if d.ID != "" {
+			calls[idx].ID = d.ID
+		}
+		if d.Function.Name != "" {
+			calls[idx].Name = d.Function.Name
+		}
+		calls[idx].Arguments += d.Function.Arguments
+	}
+	return calls
+}
+
+func (p *Provider) toRequest(req llm.ChatRequest) openai.ChatCompletionRequest {
+	messages := make([]openai.ChatCompletionMessage, len(req.Messages))
+	for i, msg := range req.Messages {
+		messages[i] = openai.ChatCompletionMessage{
+			Role:       msg.Role,
+			Content:    msg.Content,
+			Name:       msg.Name,
+			ToolCalls:  toToolCalls(msg.ToolCalls),
+			ToolCallID: msg.ToolCallID,
+		}
+	}
+
+	var tools []openai.Tool
+	for _, t := range req.Tools {
+		tools = append(tools, openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        t.Name,
+				Description: t.Description,
+				Parameters:  t.Parameters,
+			},
+		})
+	}
+
+	return openai.ChatCompletionRequest{
+		Model:       p.model(req.Model),
+		Messages:    messages,
+		Temperature: req.Temperature,
+		MaxTokens:   req.MaxTokens,
+		Tools:       tools,
+		ToolChoice:  toToolChoice(req.ToolChoice),
+	}
+}
+
+func toToolChoice(choice string) interface{} {
+	switch choice {
+	case "":
+		return nil
+	case llm.ToolChoiceAuto, llm.ToolChoiceNone, llm.ToolChoiceRequired:
+		return choice
+	default:
+		return openai.ToolChoice{
+			Type:     openai.ToolTypeFunction,
+			Function: openai.ToolFunction{Name: choice},
+		}
+	}
+}
+
+func toToolCalls(calls []llm.ToolCall) []openai.ToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	out := make([]openai.ToolCall, len(calls))
+	for i, c := range calls {
+		out[i] = openai.ToolCall{
+			ID:   c.ID,
+			Type: openai.ToolTypeFunction,
+			Function: openai.FunctionCall{
+				Name:      c.Name,
+				Arguments: c.Arguments,
+			},
+		}
+	}
+	return out
+}
+
+func fromToolCalls(calls []openai.ToolCall) []llm.ToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	out := make([]llm.ToolCall, len(calls))
+	for i, c := range calls {
+		out[i] = llm.ToolCall{
+			ID:        c.ID,
+			Name:      c.Function.Name,
+			Arguments: c.Function.Arguments,
+		}
+	}
+	return out
+}
+
+// Embed returns one embedding per input text, using the configured
+// embedding model
+func (p *Provider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if p.embeddingModel == "" {
+		return nil, fmt.Errorf("%s: no embedding model configured", p.name)
+	}
+
+	resp, err := p.client.CreateEmbeddings(ctx, openai.EmbeddingRequest{
+		Input: texts,
+		Model: openai.EmbeddingModel(p.embeddingModel),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s embeddings failed: %w", p.name, toAPIError(err))
+	}
+	if len(resp.Data) != len(texts) {
+		return nil, fmt.Errorf("%s returned %d embeddings for %d inputs", p.name, len(resp.Data), len(texts))
+	}
+
+	embeddings := make([][]float32, len(texts))
+	for _, d := range resp.Data {
+		if d.Index < 0 || d.Index >= len(texts) {
+			return nil, fmt.Errorf("%s returned embedding with invalid index %d", p.name, d.Index)
+		}
+		embeddings[d.Index] = d.Embedding
+	}
+	return embeddings, nil
+}
+
+func (p *Provider) model(model string) string {
+	if model == "" {
+		return p.defaultModel
+	}
+	return model
+}
+
+// servedModel prefers the model reported by the server, which resolves
+// aliases and routing, over the one requested
+func servedModel(reported, requested string) string {
+	if reported != "" {
+		return reported
+	}
+	return requested
+}
+
+// toAPIError converts go-openai status errors into llm.APIError so callers
+// can classify them without depending on the client library
+func toAPIError(err error) error {
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) && apiErr.HTTPStatusCode > 0 {
+		return &llm.APIError{StatusCode: apiErr.HTTPStatusCode, Message: apiErr.Message, Err: err}
+	}
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) && reqErr.HTTPStatusCode > 0 {
+		return &llm.APIError{StatusCode: reqErr.HTTPStatusCode, Err: err}
+	}
+	return err
+}
+
+// transport adds static headers to every outgoing request and surfaces
+// Retry-After on throttled responses, which go-openai would otherwise drop
+type transport struct {
+	headers map[string]string
+	base    http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if len(t.headers) > 0 {
+		req = req.Clone(req.Context())
+		for k, v := range t.headers {
+			req.Header.Set(k, v)
+		}
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	retryAfter := llm.ParseRetryAfter(resp.Header.Get("Retry-After"))
+	if retryAfter == 0 || (resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500) {
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return nil, &llm.APIError{
+		StatusCode: resp.StatusCode,
+		Message:    strings.TrimSpace(string(body)),
+		RetryAfter: retryAfter,
+	}
+}
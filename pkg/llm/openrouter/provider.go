@@ -1,65 +1,32 @@
 package openrouter
 
 import (
-	"context"
-	"fmt"
-
-	openai "github.com/sashabaranov/go-openai"
-
 	"simulacra/pkg/llm"
+	"simulacra/pkg/llm/openaicompat"
 )
 
 const defaultAPIEndpoint = "https://openrouter.ai/api/v1"
 
+// Provider is an OpenAI-compatible client preconfigured for openrouter.ai
 type Provider struct {
-	client *openai.Client
+	*openaicompat.Provider
 }
 
-func New(apiKey string) *Provider {
-	config := openai.DefaultConfig(apiKey)
-	config.BaseURL = defaultAPIEndpoint
-
-	return &Provider{
-		client: openai.NewClientWithConfig(config),
-	}
-}
+var _ llm.Provider = &Provider{}
+var _ llm.StreamingProvider = &Provider{}
 
-func (p *Provider) Name() string {
-	return "openrouter"
+func New(apiKey string) *Provider {
+	return NewWithConfig(openaicompat.Config{APIKey: apiKey})
 }
 
-func (p *Provider) ChatCompletion(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
-	messages := make([]openai.ChatCompletionMessage, len(req.Messages))
-	for i, msg := range req.Messages {
-		messages[i] = openai.ChatCompletionMessage{
-			Role:    msg.Role,
-			Content: msg.Content,
-		}
-	}
-
-	resp, err := p.client.CreateChatCompletion(
-		ctx,
-		openai.ChatCompletionRequest{
-			Model:       req.Model,
-			Messages:    messages,
-			Temperature: float32(req.Temperature),
-			MaxTokens:   req.MaxTokens,
-		},
-	)
-	if err != nil {
-		return nil, fmt.Errorf("openrouter chat completion failed: %w", err)
+// NewWithConfig creates an openrouter client, defaulting the base URL to
+// openrouter.ai when unset
+func NewWithConfig(cfg openaicompat.Config) *Provider {
+	cfg.Name = "openrouter"
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = defaultAPIEndpoint
 	}
-
-	if len(resp.Choices) == 0 {
-		return nil, fmt.Errorf("no choices in response")
+	return &Provider{
+		Provider: openaicompat.New(cfg),
 	}
-
-	return &llm.ChatResponse{
-		Content: resp.Choices[0].Message.Content,
-		Usage: llm.TokenUsage{
-			PromptTokens:     resp.Usage.PromptTokens,
-			CompletionTokens: resp.Usage.CompletionTokens,
-			TotalTokens:      resp.Usage.TotalTokens,
-		},
-	}, nil
 }
@@ -0,0 +1,53 @@
+package prompt
+
+import "time"
+
+// Built-in template names
+const (
+	NameThought      = "thought"
+	NameDeliberation = "deliberation"
+	NameAction       = "action"
+	NameReflection   = "reflection"
+	NamePlanning     = "planning"
+	NameDialogue     = "dialogue"
+	NameImportance   = "importance"
+	NameQuestions    = "questions"
+	NameSummary      = "summary"
+)
+
+// Data is the input the built-in templates expect. Custom templates may use
+// any subset of it, or be rendered with a different type altogether.
+type Data struct {
+	Name    string
+	Persona string
+	// Time is the simulation time. The zero time is left out of prompts.
+	Time time.Time
+
+	// State is the agent's own state, e.g. mood or current goal
+	State map[string]interface{}
+
+	// World is the agent's perception of the world state
+	World        map[string]interface{}
+	Observations []string
+	Memories     []string
+	Thought      string
+
+	// Reason is why a slow thought was escalated, e.g. "novelty, conflict"
+	Reason string
+
+	// Actions lists the choices available to the agent
+	Actions []string
+
+	// Question focuses a reflection
+	Question string
+
+	// Count asks for that many items, e.g. reflection questions
+	Count int
+
+	// Dialogue partner and the conversation so far
+	Partner      string
+	Conversation []string
+
+	// Extra is free-form input for custom templates
+	Extra map[string]interface{}
+}
@@ -0,0 +1,162 @@
+package prompt
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+
+	"simulacra/pkg/llm"
+)
+
+//go:embed templates/*.tmpl
+var defaults embed.FS
+
+// Template files are named <name>.v<version>.tmpl and define a "system"
+// and/or a "user" block
+var fileName = regexp.MustCompile(`^([a-z0-9_]+)\.v(\d+)\.tmpl$`)
+
+var funcs = template.FuncMap{
+	"join":  strings.Join,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"trim":  strings.TrimSpace,
+	"inc":   func(i int) int { return i + 1 },
+}
+
+// Template is a single version of a named prompt
+type Template struct {
+	Name    string
+	Version int
+	tmpl    *template.Template
+}
+
+// ID identifies the template and version, e.g. "thought@v2". Set it as
+// llm.ChatRequest.PromptID so the version is recorded with the call.
+func (t *Template) ID() string {
+	return fmt.Sprintf("%s@v%d", t.Name, t.Version)
+}
+
+// Render executes the template and returns its system and user messages.
+// Blocks that are missing or render empty are omitted.
+func (t *Template) Render(data interface{}) ([]llm.Message, error) {
+	var messages []llm.Message
+	for _, block := range []struct{ name, role string }{
+		{"system", llm.RoleSystem},
+		{"user", llm.RoleUser},
+	} {
+		if t.tmpl.Lookup(block.name) == nil {
+			continue
+		}
+		var sb strings.Builder
+		if err := t.tmpl.ExecuteTemplate(&sb, block.name, data); err != nil {
+			return nil, fmt.Errorf("render %s: %w", t.ID(), err)
+		}
+		if content := strings.TrimSpace(sb.String()); content != "" {
+			messages = append(messages, llm.Message{Role: block.role, Content: content})
+		}
+	}
+	return messages, nil
+}
+
+// Library holds every version of every known template
+type Library struct {
+	templates map[string][]*Template // sorted by version
+	mu        sync.RWMutex
+}
+
+// NewLibrary returns a library with the built-in templates loaded
+func NewLibrary() (*Library, error) {
+	l := &Library{templates: make(map[string][]*Template)}
+	sub, err := fs.Sub(defaults, "templates")
+	if err != nil {
+		return nil, err
+	}
+	if err := l.load(sub); err != nil {
+		return nil, fmt.Errorf("load built-in templates: %w", err)
+	}
+	return l, nil
+}
+
+// LoadDir adds templates from a directory on disk. A file with the same name
+// and version as an existing template replaces it, so built-ins can be edited
+// without recompiling.
+func (l *Library) LoadDir(dir string) error {
+	return l.load(os.DirFS(dir))
+}
+
+func (l *Library) load(fsys fs.FS) error {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		m := fileName.FindStringSubmatch(e.Name())
+		if e.IsDir() || m == nil {
+			continue
+		}
+		version, _ := strconv.Atoi(m[2])
+
+		b, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return err
+		}
+		tmpl, err := template.New(path.Base(e.Name())).Funcs(funcs).Parse(string(b))
+		if err != nil {
+			return fmt.Errorf("parse %s: %w", e.Name(), err)
+		}
+		l.add(&Template{Name: m[1], Version: version, tmpl: tmpl})
+	}
+	return nil
+}
+
+func (l *Library) add(t *Template) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	versions := l.templates[t.Name]
+	for i, existing := range versions {
+		if existing.Version == t.Version {
+			versions[i] = t
+			return
+		}
+	}
+	versions = append(versions, t)
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].Version < versions[j].Version
+	})
+	l.templates[t.Name] = versions
+}
+
+// Get returns the latest version of a template
+func (l *Library) Get(name string) (*Template, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	versions := l.templates[name]
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("unknown prompt template %q", name)
+	}
+	return versions[len(versions)-1], nil
+}
+
+// GetVersion returns a specific version of a template
+func (l *Library) GetVersion(name string, version int) (*Template, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	for _, t := range l.templates[name] {
+		if t.Version == version {
+			return t, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown prompt template %s@v%d", name, version)
+}
@@ -0,0 +1,161 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"simulacra/pkg/llm"
+	"simulacra/pkg/llm/contextwindow"
+)
+
+// Config sets client side limits. Zero values disable the corresponding
+// limit.
+type Config struct {
+	RequestsPerMinute int
+	TokensPerMinute   int
+	MaxInFlight       int
+	// Estimator predicts the tokens a request will consume before it is
+	// sent. Defaults to EstimateTokens.
+	Estimator func(llm.ChatRequest) int
+}
+
+// Provider wraps an llm.Provider with request, token and concurrency limits.
+// Share one instance between all agents using the same backend so the
+// limits apply to their combined traffic.
+type Provider struct {
+	provider  llm.Provider
+	requests  *rate.Limiter
+	tokens    *rate.Limiter
+	inFlight  chan struct{}
+	estimator func(llm.ChatRequest) int
+}
+
+var _ llm.Provider = &Provider{}
+var _ llm.StreamingProvider = &Provider{}
+var _ llm.Embedder = &Provider{}
+
+func New(provider llm.Provider, cfg Config) *Provider {
+	p := &Provider{
+		provider:  provider,
+		estimator: cfg.Estimator,
+	}
+	if p.estimator == nil {
+		p.estimator = EstimateTokens
+	}
+	if cfg.RequestsPerMinute > 0 {
+		p.requests = rate.NewLimiter(perMinute(cfg.RequestsPerMinute), cfg.RequestsPerMinute)
+	}
+	if cfg.TokensPerMinute > 0 {
+		p.tokens = rate.NewLimiter(perMinute(cfg.TokensPerMinute), cfg.TokensPerMinute)
+	}
+	if cfg.MaxInFlight > 0 {
+		p.inFlight = make(chan struct{}, cfg.MaxInFlight)
+	}
+	return p
+}
+
+func (p *Provider) Name() string {
+	return p.provider.Name()
+}
+
+func (p *Provider) ChatCompletion(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	estimate, release, err := p.acquire(ctx, p.estimator(req))
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	resp, err := p.provider.ChatCompletion(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	p.settle(estimate, resp)
+	return resp, nil
+}
+
+// ChatCompletionStream holds its in-flight slot until the stream ends, and
+// settles the token estimate against the usage in the final chunk
+func (p *Provider) ChatCompletionStream(ctx context.Context, req llm.ChatRequest) (<-chan llm.StreamChunk, error) {
+	estimate, release, err := p.acquire(ctx, p.estimator(req))
+	if err != nil {
+		return nil, err
+	}
+
+	chunks, err := llm.Stream(ctx, p.provider, req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	return llm.ForwardStream(ctx, chunks, func(resp *llm.ChatResponse, err error) {
+		release()
+		if resp != nil {
+			p.settle(estimate, resp)
+		}
+	}), nil
+}
+
+func (p *Provider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	tokens := 0
+	for _, t := range texts {
+		tokens += contextwindow.HeuristicCounter{}.Count(t)
+	}
+	_, release, err := p.acquire(ctx, tokens)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return llm.Embed(ctx, p.provider, texts)
+}
+
+// acquire waits until a call of the given estimated size fits within every
+// limit. It returns the estimate actually charged and a func that gives back
+// the in-flight slot.
+func (p *Provider) acquire(ctx context.Context, tokens int) (int, func(), error) {
+	if p.requests != nil {
+		if err := p.requests.Wait(ctx); err != nil {
+			return 0, nil, fmt.Errorf("request rate limit: %w", err)
+		}
+	}
+
+	estimate := 0
+	if p.tokens != nil {
+		estimate = min(tokens, p.tokens.Burst())
+		if err := p.tokens.WaitN(ctx, estimate); err != nil {
+			return 0, nil, fmt.Errorf("token rate limit: %w", err)
+		}
+	}
+
+	if p.inFlight == nil {
+		return estimate, func() {}, nil
+	}
+	select {
+	case p.inFlight <- struct{}{}:
+		return estimate, func() { <-p.inFlight }, nil
+	case <-ctx.Done():
+		return 0, nil, ctx.Err()
+	}
+}
+
+// settle charges any tokens the estimate missed against future requests
+func (p *Provider) settle(estimate int, resp *llm.ChatResponse) {
+	if p.tokens == nil {
+		return
+	}
+	if extra := resp.Usage.TotalTokens - estimate; extra > 0 {
+		p.tokens.ReserveN(time.Now(), min(extra, p.tokens.Burst()))
+	}
+}
+
+// EstimateTokens is a pre-flight estimate of the prompt tokens plus the
+// requested completion budget
+func EstimateTokens(req llm.ChatRequest) int {
+	return contextwindow.CountMessages(contextwindow.HeuristicCounter{}, req.Messages) + req.MaxTokens
+}
+
+func perMinute(n int) rate.Limit {
+	return rate.Every(time.Minute / time.Duration(n))
+}
@@ -0,0 +1,182 @@
+package recorder
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"simulacra/pkg/llm"
+	"simulacra/pkg/llm/scripted"
+)
+
+// Mode selects whether the recorder captures or replays traffic
+type Mode string
+
+const (
+	ModeRecord Mode = "record"
+	ModeReplay Mode = "replay"
+)
+
+// Recorder wraps an llm.Provider and either records every exchange to a
+// JSONL cassette or serves responses from a previously recorded one.
+type Recorder struct {
+	mode     Mode
+	provider llm.Provider
+	replay   *scripted.Provider
+	file     *os.File
+	enc      *json.Encoder
+	mu       sync.Mutex
+}
+
+var _ llm.Provider = &Recorder{}
+var _ llm.StreamingProvider = &Recorder{}
+var _ llm.Embedder = &Recorder{}
+
+// NewRecording wraps provider and writes each exchange to path. An existing
+// cassette is overwritten, since replay serves the first recorded response
+// for each request and entries from an older run would shadow this one.
+func NewRecording(provider llm.Provider, path string) (*Recorder, error) {
+	if provider == nil {
+		return nil, fmt.Errorf("provider is required in record mode")
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open cassette: %w", err)
+	}
+	return &Recorder{
+		mode:     ModeRecord,
+		provider: provider,
+		file:     f,
+		enc:      json.NewEncoder(f),
+	}, nil
+}
+
+// NewReplay serves responses recorded in path. The wrapped provider is only
+// used for its name and may be nil; it is never called.
+func NewReplay(provider llm.Provider, path string) (*Recorder, error) {
+	replay, err := scripted.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{
+		mode:     ModeReplay,
+		provider: provider,
+		replay:   replay,
+	}, nil
+}
+
+// New creates a recorder in the given mode
+func New(mode Mode, provider llm.Provider, path string) (*Recorder, error) {
+	switch mode {
+	case ModeRecord:
+		return NewRecording(provider, path)
+	case ModeReplay:
+		return NewReplay(provider, path)
+	default:
+		return nil, fmt.Errorf("unsupported recorder mode: %s", mode)
+	}
+}
+
+func (r *Recorder) Name() string {
+	if r.provider != nil {
+		return r.provider.Name()
+	}
+	return r.replay.Name()
+}
+
+func (r *Recorder) ChatCompletion(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	if r.mode == ModeReplay {
+		resp, err := r.replay.ChatCompletion(ctx, req)
+		if err != nil {
+			return nil, fmt.Errorf("replay miss: %w", err)
+		}
+		return resp, nil
+	}
+
+	resp, err := r.provider.ChatCompletion(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.record(req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// ChatCompletionStream records the assembled response once the stream
+// completes. Replayed responses arrive as a single chunk.
+func (r *Recorder) ChatCompletionStream(ctx context.Context, req llm.ChatRequest) (<-chan llm.StreamChunk, error) {
+	if r.mode == ModeReplay {
+		resp, err := r.ChatCompletion(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		return llm.StreamResponse(resp), nil
+	}
+
+	chunks, err := llm.Stream(ctx, r.provider, req)
+	if err != nil {
+		return nil, err
+	}
+	return llm.ForwardStream(ctx, chunks, func(resp *llm.ChatResponse, err error) {
+		if resp != nil {
+			// The caller already has its answer, so a failed write can only
+			// surface when the cassette is replayed
+			_ = r.record(req, resp)
+		}
+	}), nil
+}
+
+// Embed records embeddings alongside chat exchanges, so runs that embed
+// through the provider replay too
+func (r *Recorder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if r.mode == ModeReplay {
+		embeddings, err := r.replay.Embed(ctx, texts)
+		if err != nil {
+			return nil, fmt.Errorf("replay miss: %w", err)
+		}
+		return embeddings, nil
+	}
+
+	embeddings, err := llm.Embed(ctx, r.provider, texts)
+	if err != nil || len(texts) == 0 {
+		return embeddings, err
+	}
+	if err := r.write(scripted.Entry{
+		Hash:       llm.HashTexts(texts),
+		Texts:      texts,
+		Embeddings: embeddings,
+	}); err != nil {
+		return nil, err
+	}
+	return embeddings, nil
+}
+
+func (r *Recorder) record(req llm.ChatRequest, resp *llm.ChatResponse) error {
+	return r.write(scripted.Entry{
+		Hash:     llm.HashRequest(req),
+		Request:  req,
+		Response: *resp,
+	})
+}
+
+func (r *Recorder) write(e scripted.Entry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.enc.Encode(e); err != nil {
+		return fmt.Errorf("record exchange: %w", err)
+	}
+	return nil
+}
+
+// Close flushes and closes the cassette in record mode
+func (r *Recorder) Close() error {
+	if r.file == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.file.Close()
+}
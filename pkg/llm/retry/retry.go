@@ -0,0 +1,226 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+
+	"simulacra/pkg/core/logger"
+	"simulacra/pkg/llm"
+)
+
+// Config controls how failed calls are retried
+type Config struct {
+	// MaxAttempts is the total number of calls made, including the first
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	// Jitter randomises each backoff by up to this fraction, between 0 and 1
+	Jitter float64
+	// Timeout bounds each individual call; zero means no per-call timeout
+	Timeout time.Duration
+	// Classifier decides whether an error is worth retrying. Defaults to
+	// IsRetryable.
+	Classifier func(error) bool
+	Logger     *slog.Logger
+}
+
+// DefaultConfig returns a policy suitable for hosted LLM APIs
+func DefaultConfig() Config {
+	return Config{
+		MaxAttempts:    5,
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     30 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+		Timeout:        2 * time.Minute,
+	}
+}
+
+// Provider wraps an llm.Provider and retries transient failures with
+// exponential backoff
+type Provider struct {
+	provider llm.Provider
+	cfg      Config
+	log      *slog.Logger
+}
+
+var _ llm.Provider = &Provider{}
+var _ llm.StreamingProvider = &Provider{}
+var _ llm.Embedder = &Provider{}
+
+func New(provider llm.Provider, cfg Config) *Provider {
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 1
+	}
+	if cfg.Multiplier < 1 {
+		cfg.Multiplier = 1
+	}
+	if cfg.Classifier == nil {
+		cfg.Classifier = IsRetryable
+	}
+
+	log := cfg.Logger
+	if log == nil {
+		log = slog.Default()
+	}
+
+	return &Provider{
+		provider: provider,
+		cfg:      cfg,
+		log: log.With(
+			logger.CategoryKey, logger.CategoryLLM,
+			"provider", provider.Name(),
+		),
+	}
+}
+
+func (p *Provider) Name() string {
+	return p.provider.Name()
+}
+
+func (p *Provider) ChatCompletion(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	var resp *llm.ChatResponse
+	err := p.do(ctx, func() error {
+		ctx, cancel := p.withTimeout(ctx)
+		defer cancel()
+
+		var err error
+		resp, err = p.provider.ChatCompletion(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+// ChatCompletionStream retries starting the stream. Once deltas have been
+// delivered a retry would repeat them, so failures mid-stream are returned
+// as is. The per-call timeout covers the whole stream.
+func (p *Provider) ChatCompletionStream(ctx context.Context, req llm.ChatRequest) (<-chan llm.StreamChunk, error) {
+	var chunks <-chan llm.StreamChunk
+	err := p.do(ctx, func() error {
+		callCtx, cancel := p.withTimeout(ctx)
+
+		stream, err := llm.Stream(callCtx, p.provider, req)
+		if err != nil {
+			cancel()
+			return err
+		}
+		// Relay on the caller's context, since cancel ends callCtx before
+		// the final chunk is relayed
+		chunks = llm.ForwardStream(ctx, stream, func(*llm.ChatResponse, error) {
+			cancel()
+		})
+		return nil
+	})
+	return chunks, err
+}
+
+func (p *Provider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	var embeddings [][]float32
+	err := p.do(ctx, func() error {
+		ctx, cancel := p.withTimeout(ctx)
+		defer cancel()
+
+		var err error
+		embeddings, err = llm.Embed(ctx, p.provider, texts)
+		return err
+	})
+	return embeddings, err
+}
+
+// do runs call until it succeeds, fails fatally or runs out of attempts
+func (p *Provider) do(ctx context.Context, call func() error) error {
+	var lastErr error
+	for attempt := 1; ; attempt++ {
+		err := call()
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+
+		// The caller gave up, don't mistake that for a transient failure
+		if ctx.Err() != nil {
+			return err
+		}
+		if attempt >= p.cfg.MaxAttempts || !p.cfg.Classifier(err) {
+			break
+		}
+
+		wait := p.backoff(attempt, err)
+		p.log.Warn("Retrying LLM call",
+			"attempt", attempt,
+			"wait", wait,
+			"error", err,
+		)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	return fmt.Errorf("giving up after %d attempts: %w", p.cfg.MaxAttempts, lastErr)
+}
+
+// withTimeout bounds a single attempt by the per-call timeout
+func (p *Provider) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if p.cfg.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, p.cfg.Timeout)
+}
+
+// backoff returns the delay before the next attempt, honoring a server
+// supplied Retry-After when present
+func (p *Provider) backoff(attempt int, err error) time.Duration {
+	var apiErr *llm.APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		return apiErr.RetryAfter
+	}
+
+	d := float64(p.cfg.InitialBackoff) * math.Pow(p.cfg.Multiplier, float64(attempt-1))
+	if p.cfg.MaxBackoff > 0 && d > float64(p.cfg.MaxBackoff) {
+		d = float64(p.cfg.MaxBackoff)
+	}
+	if p.cfg.Jitter > 0 {
+		d += d * p.cfg.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(d)
+}
+
+// IsRetryable reports whether err looks transient: throttling, server side
+// failures, per-call timeouts and network errors. Client errors such as bad
+// requests or authentication failures are fatal.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var apiErr *llm.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusRequestTimeout, http.StatusConflict, http.StatusTooEarly, http.StatusTooManyRequests:
+			return true
+		}
+		return apiErr.StatusCode >= 500
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
@@ -0,0 +1,258 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"simulacra/pkg/llm"
+	"simulacra/pkg/llm/usage"
+)
+
+// Strategy orders the routes that match a request
+type Strategy string
+
+const (
+	// StrategyOrdered tries routes in the order they were configured
+	StrategyOrdered Strategy = "ordered"
+	// StrategyRoundRobin rotates the starting route on every call
+	StrategyRoundRobin Strategy = "round_robin"
+	// StrategyCheapest tries routes from the lowest price upwards, as given
+	// by Config.Prices for the model each route would serve
+	StrategyCheapest Strategy = "cheapest"
+)
+
+// Route is one backend the router may send a request to
+type Route struct {
+	Name     string
+	Provider llm.Provider
+	// ModelPrefixes restricts the route to requests whose model starts with
+	// one of the prefixes. Empty matches every model.
+	ModelPrefixes []string
+	// Tags restricts the route to calls tagged with one of these via
+	// WithTags. Empty matches every call.
+	Tags []string
+	// Model replaces the request model when set, e.g. to map a hosted model
+	// onto a local one
+	Model string
+}
+
+func (r Route) matches(model string, tags []string) bool {
+	if len(r.ModelPrefixes) > 0 && !hasAnyPrefix(model, r.ModelPrefixes) {
+		return false
+	}
+	if len(r.Tags) > 0 && !overlaps(r.Tags, tags) {
+		return false
+	}
+	return true
+}
+
+type Config struct {
+	Routes   []Route
+	Strategy Strategy
+	// Prices orders routes under StrategyCheapest. Pass the table given to
+	// usage.NewLedger so prices are configured in one place. Models missing
+	// from it count as free.
+	Prices usage.PriceTable
+}
+
+// Provider picks a route for each request and fails over to the next
+// matching route when a call errors
+type Provider struct {
+	routes   []Route
+	strategy Strategy
+	prices   usage.PriceTable
+	next     atomic.Uint64
+}
+
+var _ llm.Provider = &Provider{}
+var _ llm.StreamingProvider = &Provider{}
+var _ llm.Embedder = &Provider{}
+
+func New(cfg Config) (*Provider, error) {
+	if len(cfg.Routes) == 0 {
+		return nil, fmt.Errorf("router needs at least one route")
+	}
+	for i, r := range cfg.Routes {
+		if r.Provider == nil {
+			return nil, fmt.Errorf("route %d (%s) has no provider", i, r.Name)
+		}
+	}
+
+	strategy := cfg.Strategy
+	if strategy == "" {
+		strategy = StrategyOrdered
+	}
+
+	return &Provider{
+		routes:   append([]Route(nil), cfg.Routes...),
+		strategy: strategy,
+		prices:   cfg.Prices,
+	}, nil
+}
+
+func (p *Provider) Name() string {
+	return "router"
+}
+
+func (p *Provider) ChatCompletion(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	var resp *llm.ChatResponse
+	err := p.route(ctx, req, func(r Route, req llm.ChatRequest) error {
+		var err error
+		resp, err = r.Provider.ChatCompletion(ctx, req)
+		if err == nil && resp.Model == "" {
+			// Price by the route's model when the backend doesn't say
+			resp.Model = req.Model
+		}
+		return err
+	})
+	return resp, err
+}
+
+// ChatCompletionStream fails over while starting the stream. Once deltas
+// are flowing the stream is committed to its route.
+func (p *Provider) ChatCompletionStream(ctx context.Context, req llm.ChatRequest) (<-chan llm.StreamChunk, error) {
+	var chunks <-chan llm.StreamChunk
+	err := p.route(ctx, req, func(r Route, req llm.ChatRequest) error {
+		var err error
+		chunks, err = llm.Stream(ctx, r.Provider, req)
+		return err
+	})
+	return chunks, err
+}
+
+// Embed uses the first route that supports embeddings and is eligible for
+// the call's tags, failing over like chat completions
+func (p *Provider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	tags := TagsFrom(ctx)
+
+	var errs []error
+	for _, r := range p.routes {
+		if _, ok := r.Provider.(llm.Embedder); !ok {
+			continue
+		}
+		if len(r.Tags) > 0 && !overlaps(r.Tags, tags) {
+			continue
+		}
+
+		embeddings, err := llm.Embed(ctx, r.Provider, texts)
+		if err == nil {
+			return embeddings, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", routeName(r), err))
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if len(errs) == 0 {
+		return nil, fmt.Errorf("no route supports embeddings")
+	}
+	return nil, fmt.Errorf("all routes failed: %w", errors.Join(errs...))
+}
+
+// route calls each candidate route in turn until one succeeds
+func (p *Provider) route(ctx context.Context, req llm.ChatRequest, call func(Route, llm.ChatRequest) error) error {
+	candidates := p.candidates(req.Model, TagsFrom(ctx))
+	if len(candidates) == 0 {
+		return fmt.Errorf("no route for model %q", req.Model)
+	}
+
+	var errs []error
+	for _, r := range candidates {
+		routed := req
+		if r.Model != "" {
+			routed.Model = r.Model
+		}
+
+		err := call(r, routed)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", routeName(r), err))
+
+		// Nobody is waiting for an answer anymore
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return fmt.Errorf("all routes failed: %w", errors.Join(errs...))
+}
+
+func (p *Provider) candidates(model string, tags []string) []Route {
+	var matched []Route
+	for _, r := range p.routes {
+		if r.matches(model, tags) {
+			matched = append(matched, r)
+		}
+	}
+
+	// A route without its own model serves the requested one, so prices
+	// depend on the request
+	if p.strategy == StrategyCheapest {
+		sort.SliceStable(matched, func(i, j int) bool {
+			return p.price(matched[i], model) < p.price(matched[j], model)
+		})
+	}
+
+	if p.strategy == StrategyRoundRobin && len(matched) > 1 {
+		start := int(p.next.Add(1)-1) % len(matched)
+		rotated := make([]Route, 0, len(matched))
+		rotated = append(rotated, matched[start:]...)
+		matched = append(rotated, matched[:start]...)
+	}
+	return matched
+}
+
+type contextKey string
+
+const tagsKey contextKey = "router-tags"
+
+// WithTags tags calls made with ctx so that routes with matching Tags are
+// eligible, e.g. to send a group of agents to a particular backend
+func WithTags(ctx context.Context, tags ...string) context.Context {
+	return context.WithValue(ctx, tagsKey, tags)
+}
+
+// TagsFrom returns the routing tags attached to ctx
+func TagsFrom(ctx context.Context) []string {
+	tags, _ := ctx.Value(tagsKey).([]string)
+	return tags
+}
+
+func routeName(r Route) string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return r.Provider.Name()
+}
+
+func (p *Provider) price(r Route, model string) float64 {
+	if r.Model != "" {
+		model = r.Model
+	}
+	price := p.prices[model]
+	return price.PromptPerMillion + price.CompletionPerMillion
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func overlaps(a, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+package scripted
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"simulacra/pkg/llm"
+)
+
+// ErrNoResponse is returned when no scripted response matches a request
+var ErrNoResponse = errors.New("no scripted response for request")
+
+// Entry is a single request/response pair in a script or cassette.
+// If Hash is empty it is computed from Request.
+//
+// An entry with Texts records an embedding call instead: Embeddings holds
+// the result and Hash, if empty, is computed from Texts.
+type Entry struct {
+	Hash     string           `json:"hash,omitempty"`
+	Request  llm.ChatRequest  `json:"request"`
+	Response llm.ChatResponse `json:"response"`
+
+	Texts      []string    `json:"texts,omitempty"`
+	Embeddings [][]float32 `json:"embeddings,omitempty"`
+}
+
+// Provider answers chat completions and embeddings from a fixed set of
+// entries without touching the network. Identical requests are answered in
+// the order they were recorded.
+type Provider struct {
+	responses  map[string][]llm.ChatResponse
+	embeddings map[string][][][]float32
+	served     map[string]int
+	mu         sync.Mutex
+}
+
+var _ llm.Provider = &Provider{}
+var _ llm.Embedder = &Provider{}
+
+func New(entries []Entry) *Provider {
+	p := &Provider{
+		responses:  make(map[string][]llm.ChatResponse),
+		embeddings: make(map[string][][][]float32),
+		served:     make(map[string]int),
+	}
+	for _, e := range entries {
+		hash := e.Hash
+		if e.Texts != nil {
+			if hash == "" {
+				hash = llm.HashTexts(e.Texts)
+			}
+			p.embeddings[hash] = append(p.embeddings[hash], e.Embeddings)
+			continue
+		}
+		if hash == "" {
+			hash = llm.HashRequest(e.Request)
+		}
+		p.responses[hash] = append(p.responses[hash], e.Response)
+	}
+	return p
+}
+
+// Load reads a JSONL script or cassette, one Entry per line
+func Load(path string) (*Provider, error) {
+	entries, err := ReadEntries(path)
+	if err != nil {
+		return nil, err
+	}
+	return New(entries), nil
+}
+
+// ReadEntries parses a JSONL file of entries
+func ReadEntries(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open script: %w", err)
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("parse script line %d: %w", line, err)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read script: %w", err)
+	}
+	return entries, nil
+}
+
+func (p *Provider) Name() string {
+	return "scripted"
+}
+
+func (p *Provider) ChatCompletion(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	hash := llm.HashRequest(req)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	responses := p.responses[hash]
+	i := p.served[hash]
+	if i >= len(responses) {
+		return nil, fmt.Errorf("%w (hash %s, model %q)", ErrNoResponse, hash, req.Model)
+	}
+	p.served[hash] = i + 1
+
+	resp := responses[i]
+	return &resp, nil
+}
+
+func (p *Provider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	hash := llm.HashTexts(texts)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	embeddings := p.embeddings[hash]
+	// Chat and embedding hashes never collide, so they can share served
+	i := p.served[hash]
+	if i >= len(embeddings) {
+		return nil, fmt.Errorf("%w (embedding hash %s, %d texts)", ErrNoResponse, hash, len(texts))
+	}
+	p.served[hash] = i + 1
+	return embeddings[i], nil
+}
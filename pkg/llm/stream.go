@@ -0,0 +1,146 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// ErrStreamEnded is returned when a stream closes before its final chunk
+var ErrStreamEnded = errors.New("stream ended before completion")
+
+// StreamChunk is a single piece of a streamed chat completion. The last chunk
+// on a stream either has Done set, with Response holding the assembled
+// completion, or Err set if the stream failed part way.
+type StreamChunk struct {
+	Delta    string
+	Done     bool
+	Response *ChatResponse
+	Err      error
+}
+
+// StreamingProvider is implemented by providers that can yield token deltas
+// while a completion is still being generated
+type StreamingProvider interface {
+	Provider
+	// ChatCompletionStream starts a completion and returns a channel of
+	// chunks. The channel is closed after the final chunk.
+	ChatCompletionStream(ctx context.Context, req ChatRequest) (<-chan StreamChunk, error)
+}
+
+// StreamOrComplete streams the completion when the provider supports it,
+// calling onDelta for each piece of content, and otherwise falls back to a
+// single ChatCompletion call. A stream that closes without a Done chunk is an
+// error, so a cancelled completion is never mistaken for a finished one.
+func StreamOrComplete(ctx context.Context, provider Provider, req ChatRequest, onDelta func(string)) (*ChatResponse, error) {
+	sp, ok := provider.(StreamingProvider)
+	if !ok {
+		resp, err := provider.ChatCompletion(ctx, req)
+		if err == nil && onDelta != nil && resp.Content != "" {
+			onDelta(resp.Content)
+		}
+		return resp, err
+	}
+
+	chunks, err := sp.ChatCompletionStream(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var content strings.Builder
+	for chunk := range chunks {
+		if chunk.Err != nil {
+			return nil, chunk.Err
+		}
+		if chunk.Delta != "" {
+			content.WriteString(chunk.Delta)
+			if onDelta != nil {
+				onDelta(chunk.Delta)
+			}
+		}
+		if chunk.Done {
+			if chunk.Response != nil {
+				return chunk.Response, nil
+			}
+			return &ChatResponse{Content: content.String()}, nil
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return nil, ErrStreamEnded
+}
+
+// Stream starts a streamed completion. Providers that cannot stream are
+// called with ChatCompletion and their response is delivered as a single
+// delta, so decorators can always offer streaming to their callers.
+func Stream(ctx context.Context, provider Provider, req ChatRequest) (<-chan StreamChunk, error) {
+	if sp, ok := provider.(StreamingProvider); ok {
+		return sp.ChatCompletionStream(ctx, req)
+	}
+
+	resp, err := provider.ChatCompletion(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return StreamResponse(resp), nil
+}
+
+// StreamResponse returns a closed stream that delivers a complete response
+func StreamResponse(resp *ChatResponse) <-chan StreamChunk {
+	chunks := make(chan StreamChunk, 2)
+	if resp.Content != "" {
+		chunks <- StreamChunk{Delta: resp.Content}
+	}
+	chunks <- StreamChunk{Done: true, Response: resp}
+	close(chunks)
+	return chunks
+}
+
+// ForwardStream relays chunks and calls done exactly once when the stream
+// ends, with the final response or the error it ended with. done runs before
+// the final chunk is relayed, so the caller sees its effects. Decorators use
+// it to account for a streamed completion once it is complete.
+func ForwardStream(ctx context.Context, chunks <-chan StreamChunk, done func(*ChatResponse, error)) <-chan StreamChunk {
+	out := make(chan StreamChunk)
+	go func() {
+		defer close(out)
+
+		finished := false
+		finish := func(resp *ChatResponse, err error) {
+			if !finished {
+				finished = true
+				done(resp, err)
+			}
+		}
+		defer func() {
+			err := ctx.Err()
+			if err == nil {
+				err = ErrStreamEnded
+			}
+			finish(nil, err)
+		}()
+
+		var content strings.Builder
+		for chunk := range chunks {
+			content.WriteString(chunk.Delta)
+			switch {
+			case chunk.Err != nil:
+				finish(nil, chunk.Err)
+			case chunk.Done:
+				resp := chunk.Response
+				if resp == nil {
+					resp = &ChatResponse{Content: content.String()}
+				}
+				finish(resp, nil)
+			}
+
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,111 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/instructor-ai/instructor-go/pkg/instructor"
+)
+
+// DefaultStructuredRetries is the number of re-prompts Structured makes after
+// the first attempt fails to decode or validate
+const DefaultStructuredRetries = 2
+
+// Validatable may be implemented by structured output types to add checks
+// beyond the `validate` struct tags
+type Validatable interface {
+	Validate() error
+}
+
+var validate = validator.New()
+
+// Structured asks the provider for a JSON value matching the schema of T and
+// decodes it. Responses that fail to decode or validate are fed back to the
+// model with the error, up to DefaultStructuredRetries times.
+func Structured[T any](ctx context.Context, provider Provider, req ChatRequest) (T, error) {
+	return StructuredWithRetries[T](ctx, provider, req, DefaultStructuredRetries)
+}
+
+// StructuredWithRetries is Structured with an explicit retry bound
+func StructuredWithRetries[T any](ctx context.Context, provider Provider, req ChatRequest, maxRetries int) (T, error) {
+	var zero T
+
+	schema, err := instructor.NewSchema(reflect.TypeOf(zero))
+	if err != nil {
+		return zero, fmt.Errorf("generate schema: %w", err)
+	}
+
+	messages := make([]Message, 0, len(req.Messages)+1+2*maxRetries)
+	messages = append(messages, Message{
+		Role: RoleSystem,
+		Content: fmt.Sprintf("Please respond with JSON in the following JSON schema:\n\n%s\n\n"+
+			"Make sure to return an instance of the JSON, not the schema itself", schema.String),
+	})
+	messages = append(messages, req.Messages...)
+
+	var lastErr error
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		req.Messages = messages
+		resp, err := provider.ChatCompletion(ctx, req)
+		if err != nil {
+			// Provider errors are not the model's fault, so don't re-prompt
+			return zero, err
+		}
+
+		var out T
+		lastErr = decodeStructured(resp.Content, &out)
+		if lastErr == nil {
+			return out, nil
+		}
+
+		messages = append(messages,
+			Message{Role: RoleAssistant, Content: resp.Content},
+			Message{Role: RoleUser, Content: fmt.Sprintf(
+				"Your response was invalid: %v\nRespond again with only corrected JSON matching the schema.", lastErr)},
+		)
+	}
+
+	return zero, fmt.Errorf("structured output failed after %d attempts: %w", maxRetries+1, lastErr)
+}
+
+func decodeStructured(content string, out interface{}) error {
+	text := extractJSON(content)
+	if text == "" {
+		return fmt.Errorf("no JSON found in response")
+	}
+	if err := json.Unmarshal([]byte(text), out); err != nil {
+		return fmt.Errorf("decode JSON: %w", err)
+	}
+
+	v := reflect.Indirect(reflect.ValueOf(out))
+	if v.Kind() == reflect.Struct {
+		if err := validate.Struct(v.Interface()); err != nil {
+			return fmt.Errorf("validation: %w", err)
+		}
+	}
+	if val, ok := out.(Validatable); ok {
+		return val.Validate()
+	}
+	if val, ok := v.Interface().(Validatable); ok {
+		return val.Validate()
+	}
+	return nil
+}
+
+// extractJSON trims any prose the model put around the JSON value, including
+// markdown code fences
+func extractJSON(text string) string {
+	start := strings.IndexAny(text, "{[")
+	if start == -1 {
+		return ""
+	}
+	end := strings.LastIndexAny(text, "}]")
+	if end < start {
+		return ""
+	}
+	return text[start : end+1]
+}
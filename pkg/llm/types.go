@@ -1,25 +1,83 @@
 package llm
 
-import "context"
+import (
+	"context"
+	"fmt"
+)
+
+// Message roles
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleTool      = "tool"
+)
 
 // Message represents a chat message
 type Message struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
+
+	// ToolCalls are the calls requested by an assistant message
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	// ToolCallID links a tool message to the call it answers
+	ToolCallID string `json:"tool_call_id,omitempty"`
+	// Name is the name of the tool that produced a tool message
+	Name string `json:"name,omitempty"`
+}
+
+// Tool describes a function the model may call
+type Tool struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Parameters is a JSON schema object describing the arguments
+	Parameters interface{} `json:"parameters,omitempty"`
 }
 
+// ToolCall is a function call requested by the model
+type ToolCall struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name"`
+	// Arguments is the raw JSON encoded argument object
+	Arguments string `json:"arguments"`
+}
+
+// Tool choice values for ChatRequest.ToolChoice. Any other value is treated
+// as the name of the tool the model must call.
+const (
+	ToolChoiceAuto     = "auto"
+	ToolChoiceNone     = "none"
+	ToolChoiceRequired = "required"
+)
+
 // ChatRequest represents the request parameters for chat completion
 type ChatRequest struct {
 	Messages    []Message `json:"messages"`
 	Model       string    `json:"model"`
 	Temperature float32   `json:"temperature"`
 	MaxTokens   int       `json:"max_tokens,omitempty"`
+
+	// Tools the model may call, and how it should choose between them
+	Tools      []Tool `json:"tools,omitempty"`
+	ToolChoice string `json:"tool_choice,omitempty"`
+
+	// PromptID records the template and version that produced the messages,
+	// e.g. "thought@v1". It is bookkeeping only and never sent to providers.
+	PromptID string `json:"prompt_id,omitempty"`
+
+	// Options carries provider-specific settings such as Ollama's num_ctx or
+	// seed. Providers ignore keys they do not understand.
+	Options map[string]interface{} `json:"options,omitempty"`
 }
 
 // ChatResponse represents the response from a chat completion
 type ChatResponse struct {
-	Content string
-	Usage   TokenUsage
+	Content   string     `json:"content"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	Usage     TokenUsage `json:"usage"`
+	// Model is the model that actually served the request, which may differ
+	// from ChatRequest.Model when that was empty or an alias
+	Model string `json:"model,omitempty"`
 }
 
 // TokenUsage tracks token usage for the request
@@ -34,3 +92,19 @@ type Provider interface {
 	ChatCompletion(ctx context.Context, req ChatRequest) (*ChatResponse, error)
 	Name() string
 }
+
+// Embedder is implemented by providers that can produce text embeddings
+type Embedder interface {
+	// Embed returns one embedding per input text, in order
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// Embed calls provider's Embed when it is an Embedder. Decorators use it to
+// pass embeddings through to the provider they wrap.
+func Embed(ctx context.Context, provider Provider, texts []string) ([][]float32, error) {
+	embedder, ok := provider.(Embedder)
+	if !ok {
+		return nil, fmt.Errorf("provider %s does not support embeddings", provider.Name())
+	}
+	return embedder.Embed(ctx, texts)
+}
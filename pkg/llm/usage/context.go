@@ -0,0 +1,47 @@
+package usage
+
+import "context"
+
+type contextKey string
+
+const (
+	agentKey contextKey = "usage-agent"
+	stepKey  contextKey = "usage-step"
+	runKey   contextKey = "usage-run"
+)
+
+// WithAgent attributes LLM calls made with ctx to an agent
+func WithAgent(ctx context.Context, agentID string) context.Context {
+	return context.WithValue(ctx, agentKey, agentID)
+}
+
+// WithStep attributes LLM calls made with ctx to a simulation step
+func WithStep(ctx context.Context, step int) context.Context {
+	return context.WithValue(ctx, stepKey, step)
+}
+
+// WithRun attributes LLM calls made with ctx to a simulation run
+func WithRun(ctx context.Context, runID string) context.Context {
+	return context.WithValue(ctx, runKey, runID)
+}
+
+// AgentFrom returns the agent ID attached to ctx, if any
+func AgentFrom(ctx context.Context) string {
+	id, _ := ctx.Value(agentKey).(string)
+	return id
+}
+
+// StepFrom returns the simulation step attached to ctx, or -1
+func StepFrom(ctx context.Context) int {
+	step, ok := ctx.Value(stepKey).(int)
+	if !ok {
+		return -1
+	}
+	return step
+}
+
+// RunFrom returns the run ID attached to ctx, if any
+func RunFrom(ctx context.Context) string {
+	id, _ := ctx.Value(runKey).(string)
+	return id
+}
@@ -0,0 +1,186 @@
+package usage
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"simulacra/pkg/core/event"
+	"simulacra/pkg/llm"
+)
+
+// Price is the cost of a model in dollars per million tokens
+type Price struct {
+	PromptPerMillion     float64
+	CompletionPerMillion float64
+}
+
+// PriceTable maps model names to prices. Unknown models cost nothing.
+type PriceTable map[string]Price
+
+// Cost estimates the dollar cost of a call
+func (t PriceTable) Cost(model string, u llm.TokenUsage) float64 {
+	p, ok := t[model]
+	if !ok {
+		return 0
+	}
+	return (float64(u.PromptTokens)*p.PromptPerMillion + float64(u.CompletionTokens)*p.CompletionPerMillion) / 1e6
+}
+
+// Entry is a single attributed LLM call
+type Entry struct {
+	AgentID   string
+	Step      int
+	RunID     string
+	Model     string
+	PromptID  string
+	Usage     llm.TokenUsage
+	Cost      float64
+	Timestamp time.Time
+}
+
+// Summary aggregates usage over many calls
+type Summary struct {
+	Requests         int
+	PromptTokens     int
+	CompletionTokens int
+	TotalTokens      int
+	Cost             float64
+}
+
+func (s *Summary) add(e Entry) {
+	s.Requests++
+	s.PromptTokens += e.Usage.PromptTokens
+	s.CompletionTokens += e.Usage.CompletionTokens
+	s.TotalTokens += e.Usage.TotalTokens
+	s.Cost += e.Cost
+}
+
+// runStep identifies a step within a run, since step numbers restart at 1
+// in every run
+type runStep struct {
+	run  string
+	step int
+}
+
+// Ledger accumulates token usage and cost per agent, step and run
+type Ledger struct {
+	prices  PriceTable
+	bus     event.Bus
+	total   Summary
+	byAgent map[string]*Summary
+	byStep  map[runStep]*Summary
+	byRun   map[string]*Summary
+	mu      sync.RWMutex
+}
+
+// NewLedger creates a ledger. If bus is not nil every recorded call is
+// published as an event.TypeLLMUsage event.
+func NewLedger(prices PriceTable, bus event.Bus) *Ledger {
+	return &Ledger{
+		prices:  prices,
+		bus:     bus,
+		byAgent: make(map[string]*Summary),
+		byStep:  make(map[runStep]*Summary),
+		byRun:   make(map[string]*Summary),
+	}
+}
+
+// Record attributes a call to the agent, step and run found in ctx. The call
+// is priced by the model that served it, falling back to the requested one
+// for providers that do not report it.
+func (l *Ledger) Record(ctx context.Context, req llm.ChatRequest, resp *llm.ChatResponse) Entry {
+	model := resp.Model
+	if model == "" {
+		model = req.Model
+	}
+
+	e := Entry{
+		AgentID:   AgentFrom(ctx),
+		Step:      StepFrom(ctx),
+		RunID:     RunFrom(ctx),
+		Model:     model,
+		PromptID:  req.PromptID,
+		Usage:     resp.Usage,
+		Cost:      l.prices.Cost(model, resp.Usage),
+		Timestamp: time.Now(),
+	}
+
+	l.mu.Lock()
+	l.total.add(e)
+	summaryFor(l.byAgent, e.AgentID).add(e)
+	summaryFor(l.byStep, runStep{e.RunID, e.Step}).add(e)
+	summaryFor(l.byRun, e.RunID).add(e)
+	l.mu.Unlock()
+
+	if l.bus != nil {
+		_ = l.bus.Publish(event.Event{
+			Type:      event.TypeLLMUsage,
+			Source:    e.AgentID,
+			Timestamp: e.Timestamp,
+			Data: map[string]interface{}{
+				"run":               e.RunID,
+				"step":              e.Step,
+				"model":             e.Model,
+				"prompt":            e.PromptID,
+				"prompt_tokens":     e.Usage.PromptTokens,
+				"completion_tokens": e.Usage.CompletionTokens,
+				"total_tokens":      e.Usage.TotalTokens,
+				"cost":              e.Cost,
+			},
+		})
+	}
+	return e
+}
+
+// Total returns usage across all calls
+func (l *Ledger) Total() Summary {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.total
+}
+
+// Agent returns usage attributed to an agent
+func (l *Ledger) Agent(agentID string) Summary {
+	return get(l, l.byAgent, agentID)
+}
+
+// Step returns usage attributed to a step of a run
+func (l *Ledger) Step(runID string, step int) Summary {
+	return get(l, l.byStep, runStep{runID, step})
+}
+
+// Run returns usage attributed to a run
+func (l *Ledger) Run(runID string) Summary {
+	return get(l, l.byRun, runID)
+}
+
+// Agents returns usage for every agent seen so far
+func (l *Ledger) Agents() map[string]Summary {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	ret := make(map[string]Summary, len(l.byAgent))
+	for id, s := range l.byAgent {
+		ret[id] = *s
+	}
+	return ret
+}
+
+func summaryFor[K comparable](m map[K]*Summary, key K) *Summary {
+	s, ok := m[key]
+	if !ok {
+		s = &Summary{}
+		m[key] = s
+	}
+	return s
+}
+
+func get[K comparable](l *Ledger, m map[K]*Summary, key K) Summary {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if s, ok := m[key]; ok {
+		return *s
+	}
+	return Summary{}
+}
@@ -0,0 +1,56 @@
+package usage
+
+import (
+	"context"
+
+	"simulacra/pkg/llm"
+)
+
+// Provider wraps an llm.Provider and records the usage of every successful
+// call in a ledger
+type Provider struct {
+	provider llm.Provider
+	ledger   *Ledger
+}
+
+var _ llm.Provider = &Provider{}
+var _ llm.StreamingProvider = &Provider{}
+var _ llm.Embedder = &Provider{}
+
+func NewProvider(provider llm.Provider, ledger *Ledger) *Provider {
+	return &Provider{
+		provider: provider,
+		ledger:   ledger,
+	}
+}
+
+func (p *Provider) Name() string {
+	return p.provider.Name()
+}
+
+func (p *Provider) ChatCompletion(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	resp, err := p.provider.ChatCompletion(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	p.ledger.Record(ctx, req, resp)
+	return resp, nil
+}
+
+// ChatCompletionStream records usage from the final chunk once the stream
+// completes
+func (p *Provider) ChatCompletionStream(ctx context.Context, req llm.ChatRequest) (<-chan llm.StreamChunk, error) {
+	chunks, err := llm.Stream(ctx, p.provider, req)
+	if err != nil {
+		return nil, err
+	}
+	return llm.ForwardStream(ctx, chunks, func(resp *llm.ChatResponse, err error) {
+		if resp != nil {
+			p.ledger.Record(ctx, req, resp)
+		}
+	}), nil
+}
+
+func (p *Provider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return llm.Embed(ctx, p.provider, texts)
+}
@@ -2,26 +2,58 @@ package memory
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"simulacra/pkg/core/action"
 	"simulacra/pkg/core/agent"
 	"simulacra/pkg/core/logger"
+	"simulacra/pkg/core/store"
+	"strings"
+	"sync"
 )
 
 type AgentMemoryPlugin struct {
-	memory Memory
-	log    *slog.Logger
+	memory    *MemoryStore
+	reflector *Reflector
+	log       *slog.Logger
+
+	// The previous thought seeds retrieval for the next one
+	lastThought string
+	mu          sync.Mutex
 }
 
 var _ agent.AgentPlugin = &AgentMemoryPlugin{}
 
-func NewAgentMemoryPlugin(ctx context.Context) *AgentMemoryPlugin {
+// NewAgentMemoryPlugin creates the memory plugin for one agent. Memories are
+// persisted to store.DefaultStore() under cfg.AgentID unless cfg.Store is set.
+// Set cfg.Provider, usually the agent's own, to have the LLM rate importance.
+func NewAgentMemoryPlugin(ctx context.Context, cfg Config) (*AgentMemoryPlugin, error) {
+	if cfg.Capacity == 0 {
+		cfg.Capacity = 100
+	}
+	if cfg.Store == nil && cfg.AgentID != "" {
+		cfg.Store = store.DefaultStore()
+	}
+
+	memory, err := NewMemoryStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var reflector *Reflector
+	if cfg.Reflection != nil {
+		if reflector, err = NewReflector(memory, *cfg.Reflection); err != nil {
+			return nil, err
+		}
+	}
+
 	return &AgentMemoryPlugin{
-		memory: NewMemoryStore(100, nil),
+		memory:    memory,
+		reflector: reflector,
 		log: ctx.Value(logger.Key).(*slog.Logger).With(
 			logger.CategoryKey, logger.CategoryPlugin,
 			"name", "AgentMemoryPlugin"),
-	}
+	}, nil
 }
 
 func (p *AgentMemoryPlugin) GetDescription() string {
@@ -50,14 +82,92 @@ func (p *AgentMemoryPlugin) OnAction(ctx context.Context, action action.Action)
 	return nil
 }
 
+// GetMemory returns the agent's memory stream
+func (p *AgentMemoryPlugin) GetMemory() *MemoryStore {
+	return p.memory
+}
+
+// PreThink retrieves the memories most relevant to what the agent just
+// observed and was last thinking about. A slow thought first reflects on
+// anything new, so its insights are retrieved with the rest.
 func (p *AgentMemoryPlugin) PreThink(ctx context.Context, thought *agent.Thought) error {
+	if thought.Type == agent.ThoughtTypeSlow {
+		p.reflect(ctx, true)
+	}
+
+	p.mu.Lock()
+	cues := append([]string{p.lastThought}, thought.Observations...)
+	p.mu.Unlock()
+	query := strings.TrimSpace(strings.Join(cues, "\n"))
+
+	k := p.memory.retrieval.TopK
+	if thought.Type == agent.ThoughtTypeSlow {
+		k *= 2
+	}
+	results, err := p.memory.Search(ctx, query, k)
+	if err != nil {
+		return fmt.Errorf("retrieve memories: %w", err)
+	}
+	for _, r := range results {
+		thought.Memories = append(thought.Memories, r.Text())
+	}
 	return nil
 }
 
+// PostThink records the observations that led to the thought, and the
+// thought itself, in the memory stream. They are scored for importance
+// together in one batch, and trigger a reflection once enough has happened.
 func (p *AgentMemoryPlugin) PostThink(ctx context.Context, thought *agent.Thought) error {
+	var mems []TimestampedMemory
+	for _, o := range thought.Observations {
+		mems = append(mems, TimestampedMemory{Content: o, Type: TypeObservation})
+	}
+	if thought.Content != "" {
+		mems = append(mems, TimestampedMemory{
+			Content: thought.Content,
+			Type:    TypeThought,
+			Metadata: map[string]interface{}{
+				"thought_type": thought.Type,
+			},
+		})
+	}
+	if len(mems) == 0 {
+		return nil
+	}
+
+	if _, err := p.memory.AddBatch(ctx, mems); err != nil {
+		return err
+	}
+	if thought.Content != "" {
+		p.mu.Lock()
+		p.lastThought = thought.Content
+		p.mu.Unlock()
+	}
+
+	p.reflect(ctx, false)
 	return nil
 }
 
+// reflect runs a reflection if one is due, or when eager as soon as anything
+// has happened since the last one. Failures are logged rather than returned;
+// the reflector moves past the memories it tried, so the next attempt waits
+// until enough new importance has built up.
+func (p *AgentMemoryPlugin) reflect(ctx context.Context, eager bool) {
+	if p.reflector == nil {
+		return
+	}
+	if !p.reflector.Due() && !(eager && p.reflector.Pending() > 0) {
+		return
+	}
+
+	reflections, err := p.reflector.Reflect(ctx)
+	if err != nil {
+		p.log.Warn("Reflection failed", "error", err)
+		return
+	}
+	p.log.Info("Reflected", "insights", len(reflections))
+}
+
 func (p *AgentMemoryPlugin) PreAction(ctx context.Context, action action.Action) error {
 	return nil
 }
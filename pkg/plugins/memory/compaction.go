@@ -0,0 +1,348 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+
+	"simulacra/pkg/llm"
+	"simulacra/pkg/llm/prompt"
+)
+
+type CompactionPolicy string
+
+const (
+	// CompactionEvict drops the memories least worth keeping
+	CompactionEvict CompactionPolicy = "evict"
+	// CompactionSummarize merges old low-importance memories into LLM
+	// written summaries, then evicts if that was not enough
+	CompactionSummarize CompactionPolicy = "summarize"
+)
+
+// CompactionConfig decides what happens when a memory stream reaches its
+// capacity
+type CompactionConfig struct {
+	Policy CompactionPolicy
+	// Target is the fraction of capacity to compact down to, leaving
+	// headroom so compaction does not run on every new memory
+	Target float64
+
+	// Provider and Model write summaries under CompactionSummarize
+	Provider llm.Provider
+	Model    string
+	// Prompts defaults to the embedded template library
+	Prompts *prompt.Library
+	// Name of the agent, used in the summary prompt
+	Name string
+	// SummarizeBelow is the importance under which memories may be merged
+	SummarizeBelow MemoryScore
+	// BatchSize is the most memories merged into one summary
+	BatchSize int
+
+	// Forgetting enables the forgetting curve
+	Forgetting *ForgettingConfig
+}
+
+// DefaultCompactionConfig evicts down to 90% of capacity
+func DefaultCompactionConfig() CompactionConfig {
+	return CompactionConfig{
+		Policy:         CompactionEvict,
+		Target:         0.9,
+		SummarizeBelow: MemoryScoreMedium,
+		BatchSize:      10,
+	}
+}
+
+// ForgettingConfig is an Ebbinghaus-style forgetting curve. A memory's
+// retention decays as exp(-t/S), where t is the time since it was last
+// retrieved and its stability S grows with importance and with every
+// retrieval.
+type ForgettingConfig struct {
+	// Stability of a memory with MemoryScoreLow that was never retrieved
+	Stability time.Duration
+	// Reinforcement multiplies stability on each retrieval
+	Reinforcement float64
+	// Threshold is the retention under which a memory is forgotten
+	Threshold float64
+}
+
+// DefaultForgettingConfig returns a curve under which an unimportant,
+// never retrieved memory is forgotten after about three days
+func DefaultForgettingConfig() ForgettingConfig {
+	return ForgettingConfig{
+		Stability:     24 * time.Hour,
+		Reinforcement: 2,
+		Threshold:     0.05,
+	}
+}
+
+// withDefaults fills zero fields from the defaults and checks the policy can
+// be carried out
+func (c CompactionConfig) withDefaults() (CompactionConfig, error) {
+	defaults := DefaultCompactionConfig()
+	if c.Policy == "" {
+		c.Policy = defaults.Policy
+	}
+	if c.Target <= 0 || c.Target > 1 {
+		c.Target = defaults.Target
+	}
+	if c.SummarizeBelow == 0 {
+		c.SummarizeBelow = defaults.SummarizeBelow
+	}
+	if c.BatchSize < 2 {
+		c.BatchSize = defaults.BatchSize
+	}
+
+	switch c.Policy {
+	case CompactionEvict:
+	case CompactionSummarize:
+		if c.Provider == nil {
+			return c, fmt.Errorf("summarize compaction requires an LLM provider")
+		}
+		if c.Prompts == nil {
+			prompts, err := prompt.NewLibrary()
+			if err != nil {
+				return c, err
+			}
+			c.Prompts = prompts
+		}
+	default:
+		return c, fmt.Errorf("unknown compaction policy: %s", c.Policy)
+	}
+
+	if c.Forgetting != nil {
+		forgetting := *c.Forgetting
+		fd := DefaultForgettingConfig()
+		if forgetting.Stability == 0 {
+			forgetting.Stability = fd.Stability
+		}
+		if forgetting.Reinforcement == 0 {
+			forgetting.Reinforcement = fd.Reinforcement
+		}
+		if forgetting.Threshold == 0 {
+			forgetting.Threshold = fd.Threshold
+		}
+		c.Forgetting = &forgetting
+	}
+	return c, nil
+}
+
+// Retention returns how well a memory is remembered at now, between 0 and 1
+func (f ForgettingConfig) Retention(mem TimestampedMemory, now time.Time) float64 {
+	importance := max(float64(mem.Importance), float64(MemoryScoreLow))
+	stability := f.Stability.Hours() * importance * math.Pow(f.Reinforcement, float64(mem.Retrievals))
+	hours := max(float64(now.UnixNano()-mem.LastAccessed)/float64(time.Hour), 0)
+	return math.Exp(-hours / stability)
+}
+
+// Compact brings a stream that is over capacity back down to the compaction
+// target. Forgotten memories go first, then old low-importance memories are
+// summarized if the policy says so, and finally the memories least worth
+// keeping are evicted.
+func (m *MemoryStore) Compact(ctx context.Context) error {
+	m.compactMu.Lock()
+	defer m.compactMu.Unlock()
+
+	if m.capacity <= 0 || m.Len() <= m.capacity {
+		return nil
+	}
+	target := int(float64(m.capacity) * m.compaction.Target)
+	now := m.now()
+
+	if m.compaction.Forgetting != nil {
+		if err := m.forget(now); err != nil {
+			return err
+		}
+	}
+	if m.compaction.Policy == CompactionSummarize && m.Len() > target {
+		if err := m.summarize(ctx, target); err != nil {
+			return err
+		}
+	}
+	return m.evict(now, target)
+}
+
+// forget drops the memories whose retention has fallen below the threshold
+func (m *MemoryStore) forget(now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	forgotten := make(map[string]bool)
+	for _, mem := range m.memories {
+		if m.compaction.Forgetting.Retention(mem, now) < m.compaction.Forgetting.Threshold {
+			forgotten[mem.ID] = true
+		}
+	}
+	return m.remove(forgotten, nil)
+}
+
+// evict drops the memories least worth keeping until at most target remain
+func (m *MemoryStore) evict(now time.Time, target int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	excess := len(m.memories) - target
+	if excess <= 0 {
+		return nil
+	}
+
+	ranked := make([]ScoredMemory, len(m.memories))
+	for i, mem := range m.memories {
+		ranked[i] = ScoredMemory{TimestampedMemory: mem, Score: m.keepScore(mem, now)}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Score < ranked[j].Score
+	})
+
+	evicted := make(map[string]bool, excess)
+	for _, r := range ranked[:excess] {
+		evicted[r.ID] = true
+	}
+	return m.remove(evicted, nil)
+}
+
+// keepScore weighs importance against recency, or against retention when the
+// forgetting curve is enabled
+func (m *MemoryStore) keepScore(mem TimestampedMemory, now time.Time) float64 {
+	var recency float64
+	if m.compaction.Forgetting != nil {
+		recency = m.compaction.Forgetting.Retention(mem, now)
+	} else {
+		hours := float64(now.UnixNano()-mem.LastAccessed) / float64(time.Hour)
+		recency = math.Pow(m.retrieval.DecayFactor, max(hours, 0))
+	}
+	return m.retrieval.ImportanceWeight*float64(mem.Importance)/float64(MemoryScoreMax) +
+		m.retrieval.RecencyWeight*recency
+}
+
+// summarize merges the oldest low-importance memories into summaries until
+// at most target remain or there is nothing left to merge. Reflections and
+// earlier summaries are never merged.
+func (m *MemoryStore) summarize(ctx context.Context, target int) error {
+	m.mu.RLock()
+	var candidates []TimestampedMemory
+	for _, mem := range m.memories {
+		if mem.Importance < m.compaction.SummarizeBelow && mem.Type != TypeReflection && mem.Type != TypeSummary {
+			candidates = append(candidates, mem)
+		}
+	}
+	excess := len(m.memories) - target
+	m.mu.RUnlock()
+
+	// Each summary of n memories frees n-1 slots
+	var summaries []TimestampedMemory
+	merged := make(map[string]bool)
+	for len(candidates) >= 2 && excess > 0 {
+		n := min(m.compaction.BatchSize, len(candidates), excess+1)
+		chunk := candidates[:n]
+		candidates = candidates[n:]
+		excess -= n - 1
+
+		summary, err := m.summarizeChunk(ctx, chunk)
+		if err != nil {
+			return err
+		}
+		summaries = append(summaries, summary)
+		for _, mem := range chunk {
+			merged[mem.ID] = true
+		}
+	}
+	if len(summaries) == 0 {
+		return nil
+	}
+
+	if err := m.prepare(ctx, summaries); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range summaries {
+		m.nextID++
+		summaries[i].ID = strconv.Itoa(m.nextID)
+	}
+	return m.remove(merged, summaries)
+}
+
+// summarizeChunk asks the LLM to merge memories into one. The summary takes
+// the place of the newest memory it replaces, and keeps the highest
+// importance among them.
+func (m *MemoryStore) summarizeChunk(ctx context.Context, chunk []TimestampedMemory) (TimestampedMemory, error) {
+	tmpl, err := m.compaction.Prompts.Get(prompt.NameSummary)
+	if err != nil {
+		return TimestampedMemory{}, err
+	}
+	messages, err := tmpl.Render(prompt.Data{
+		Name:     m.compaction.Name,
+		Memories: texts(chunk),
+	})
+	if err != nil {
+		return TimestampedMemory{}, err
+	}
+
+	resp, err := m.compaction.Provider.ChatCompletion(ctx, llm.ChatRequest{
+		Model:    m.compaction.Model,
+		Messages: messages,
+		PromptID: tmpl.ID(),
+	})
+	if err != nil {
+		return TimestampedMemory{}, fmt.Errorf("summarize memories: %w", err)
+	}
+
+	summary := TimestampedMemory{
+		Content: strings.TrimSpace(resp.Content),
+		Type:    TypeSummary,
+	}
+	sources := make([]string, len(chunk))
+	for i, mem := range chunk {
+		sources[i] = mem.ID
+		summary.Timestamp = max(summary.Timestamp, mem.Timestamp)
+		summary.LastAccessed = max(summary.LastAccessed, mem.LastAccessed)
+		summary.Importance = max(summary.Importance, mem.Importance)
+	}
+	summary.Metadata = map[string]interface{}{"sources": sources}
+	return summary, nil
+}
+
+// remove deletes the memories with the given IDs and inserts replacements in
+// timestamp order, in memory and in the store. The caller must hold m.mu.
+func (m *MemoryStore) remove(ids map[string]bool, replacements []TimestampedMemory) error {
+	if len(ids) == 0 && len(replacements) == 0 {
+		return nil
+	}
+
+	kept := make([]TimestampedMemory, 0, len(m.memories)-len(ids)+len(replacements))
+	var removed []TimestampedMemory
+	for _, mem := range m.memories {
+		if ids[mem.ID] {
+			removed = append(removed, mem)
+		} else {
+			kept = append(kept, mem)
+		}
+	}
+	kept = append(kept, replacements...)
+	sort.SliceStable(kept, func(i, j int) bool {
+		return kept[i].Timestamp < kept[j].Timestamp
+	})
+
+	if m.store != nil {
+		batch := new(leveldb.Batch)
+		for _, mem := range removed {
+			batch.Delete(m.key(mem))
+		}
+		if err := m.store.Write(batch, nil); err != nil {
+			return fmt.Errorf("delete memories: %w", err)
+		}
+	}
+	if err := m.persist(replacements...); err != nil {
+		return err
+	}
+	m.memories = kept
+	return nil
+}
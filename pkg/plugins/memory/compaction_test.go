@@ -0,0 +1,44 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestAddBatchSurvivesFailedCompaction(t *testing.T) {
+	ctx := context.Background()
+	provider := &reflector{err: errors.New("model unavailable")}
+	m, err := NewMemoryStore(Config{
+		Capacity: 4,
+		Compaction: &CompactionConfig{
+			Policy:   CompactionSummarize,
+			Provider: provider,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 6; i++ {
+		if _, err := m.Add(ctx, TimestampedMemory{Content: fmt.Sprintf("memory %d", i), Type: TypeObservation}); err != nil {
+			t.Fatalf("Add(%d) = %v, want compaction failures logged", i, err)
+		}
+	}
+	if m.Len() != 6 {
+		t.Errorf("Len() = %d, want every memory kept until compaction succeeds", m.Len())
+	}
+	if provider.calls == 0 {
+		t.Fatal("compaction never asked for a summary")
+	}
+
+	// The next add retries and, once the model is back, compacts
+	provider.err = nil
+	if _, err := m.Add(ctx, TimestampedMemory{Content: "memory 6", Type: TypeObservation}); err != nil {
+		t.Fatal(err)
+	}
+	if m.Len() > 4 {
+		t.Errorf("Len() = %d after a successful compaction, want at most 4", m.Len())
+	}
+}
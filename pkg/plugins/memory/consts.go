@@ -0,0 +1,7 @@
+package memory
+
+const (
+	KeyPrefix = "agent-memory"
+	// WatermarkKeyPrefix holds how far each agent has reflected
+	WatermarkKeyPrefix = "agent-reflection"
+)
@@ -2,7 +2,24 @@ package memory
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+
+	"simulacra/pkg/core/logger"
 	"simulacra/pkg/core/store"
+	"simulacra/pkg/llm"
+	"simulacra/pkg/llm/hashembed"
 )
 
 type MemoryScore int
@@ -13,38 +30,575 @@ var (
 	MemoryScoreHigh   MemoryScore = 9
 )
 
+// MemoryScoreMax is the top of the 1-10 importance scale
+const MemoryScoreMax MemoryScore = 10
+
+// Memory types
+const (
+	TypeObservation = "observation"
+	TypeThought     = "thought"
+	TypeReflection  = "reflection"
+	TypeSummary     = "summary"
+)
+
 type Memory interface {
 	Retrieve(ctx context.Context, query string, threshold MemoryScore) (string, error)
 	Store(ctx context.Context, memory string, score MemoryScore) error
 }
 
+// RetrievalConfig weighs the three retrieval signals of the Generative Agents
+// memory stream. Each signal is min-max normalised across candidates before
+// weighting.
+type RetrievalConfig struct {
+	RecencyWeight    float64
+	ImportanceWeight float64
+	RelevanceWeight  float64
+	// DecayFactor is applied per hour since a memory was last accessed
+	DecayFactor float64
+	TopK        int
+}
+
+// DefaultRetrievalConfig returns the equal weighting used in the paper
+func DefaultRetrievalConfig() RetrievalConfig {
+	return RetrievalConfig{
+		RecencyWeight:    1,
+		ImportanceWeight: 1,
+		RelevanceWeight:  1,
+		DecayFactor:      0.995,
+		TopK:             10,
+	}
+}
+
+// withDefaults fills zero fields from DefaultRetrievalConfig. A single zero
+// weight switches that signal off, but all three being zero means none were
+// set.
+func (c RetrievalConfig) withDefaults() RetrievalConfig {
+	defaults := DefaultRetrievalConfig()
+	if c.RecencyWeight == 0 && c.ImportanceWeight == 0 && c.RelevanceWeight == 0 {
+		c.RecencyWeight = defaults.RecencyWeight
+		c.ImportanceWeight = defaults.ImportanceWeight
+		c.RelevanceWeight = defaults.RelevanceWeight
+	}
+	if c.DecayFactor == 0 {
+		c.DecayFactor = defaults.DecayFactor
+	}
+	if c.TopK == 0 {
+		c.TopK = defaults.TopK
+	}
+	return c
+}
+
+// Config configures a MemoryStore
+type Config struct {
+	Capacity int
+	// Store persists memories when set, namespaced by AgentID
+	Store   store.DefaultStoreType
+	AgentID string
+	// Embedder computes relevance, defaults to a local hashing embedder
+	Embedder  llm.Embedder
+	Retrieval RetrievalConfig
+	// Scorer rates memories stored without an importance. It defaults to an
+	// LLMScorer when Provider is set, and to HeuristicScorer for offline runs.
+	Scorer ImportanceScorer
+	// Provider and Model ask the LLM to rate importance, from the point of
+	// view of the agent called Name
+	Provider llm.Provider
+	Model    string
+	Name     string
+	// Reflection enables periodic reflection in AgentMemoryPlugin
+	Reflection *ReflectionConfig
+	// Compaction applies once Capacity is reached, defaults to
+	// DefaultCompactionConfig. A zero Capacity means unbounded.
+	Compaction *CompactionConfig
+	// Now is the simulation clock, e.g. Simulation.Now. Timestamps, recency
+	// and forgetting all follow it, so time a stopped simulation spends
+	// offline does not age memories. Defaults to wall-clock time, which is
+	// also used whenever Now returns the zero time, as Simulation.Now does
+	// without a StartTime.
+	Now func() time.Time
+}
+
 type MemoryStore struct {
-	memories []TimestampedMemory
-	capacity int
-	store    store.DefaultStoreType
+	memories   []TimestampedMemory
+	capacity   int
+	store      store.DefaultStoreType
+	agentID    string
+	embedder   llm.Embedder
+	scorer     ImportanceScorer
+	retrieval  RetrievalConfig
+	compaction CompactionConfig
+	now        func() time.Time
+	nextID     int
+	// lastStamp keeps memories stamped within one clock tick in order
+	lastStamp int64
+	log       *slog.Logger
+	mu        sync.RWMutex
+
+	// Serialises compactions, which call the LLM without holding mu
+	compactMu sync.Mutex
 }
 
 var _ Memory = &MemoryStore{}
 
 type TimestampedMemory struct {
-	Timestamp int64
-	Content   interface{}
-	Type      string
-	Metadata  map[string]interface{}
+	ID        string                 `json:"id"`
+	Timestamp int64                  `json:"timestamp"`
+	Content   interface{}            `json:"content"`
+	Type      string                 `json:"type"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+
+	Importance   MemoryScore `json:"importance"`
+	Embedding    []float32   `json:"embedding,omitempty"`
+	LastAccessed int64       `json:"last_accessed"`
+	// Retrievals counts how often the memory was returned by Search, which
+	// reinforces it against forgetting
+	Retrievals int `json:"retrievals,omitempty"`
+}
+
+// Text returns the memory content as a string
+func (m TimestampedMemory) Text() string {
+	if s, ok := m.Content.(string); ok {
+		return s
+	}
+	return fmt.Sprint(m.Content)
+}
+
+// ScoredMemory is a retrieval result
+type ScoredMemory struct {
+	TimestampedMemory
+	Score float64
 }
 
-func NewMemoryStore(capacity int, store store.DefaultStoreType) *MemoryStore {
-	return &MemoryStore{
-		memories: make([]TimestampedMemory, 0, capacity),
-		capacity: capacity,
-		store:    store,
+// NewMemoryStore creates a memory stream. With a store configured, memories
+// previously persisted for the agent are loaded back in timestamp order.
+func NewMemoryStore(cfg Config) (*MemoryStore, error) {
+	if cfg.Store != nil && cfg.AgentID == "" {
+		return nil, fmt.Errorf("agent ID is required for persistent memory")
+	}
+
+	embedder := cfg.Embedder
+	if embedder == nil {
+		embedder = hashembed.New(hashembed.DefaultDimensions)
+	}
+	retrieval := cfg.Retrieval.withDefaults()
+	scorer := cfg.Scorer
+	switch {
+	case scorer != nil:
+	case cfg.Provider != nil:
+		llmScorer, err := NewLLMScorer(cfg.Provider, cfg.Model, nil, cfg.Name)
+		if err != nil {
+			return nil, err
+		}
+		scorer = llmScorer
+	default:
+		scorer = HeuristicScorer{}
+	}
+	compaction := DefaultCompactionConfig()
+	if cfg.Compaction != nil {
+		compaction = *cfg.Compaction
+	}
+	compaction, err := compaction.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now
+	if clock := cfg.Now; clock != nil {
+		now = func() time.Time {
+			// Stamping from the zero time would squash every memory into the
+			// first nanoseconds of year 1 and defeat recency and forgetting
+			if t := clock(); !t.IsZero() {
+				return t
+			}
+			return time.Now()
+		}
+	}
+
+	m := &MemoryStore{
+		memories:   make([]TimestampedMemory, 0, cfg.Capacity),
+		capacity:   cfg.Capacity,
+		store:      cfg.Store,
+		agentID:    cfg.AgentID,
+		embedder:   embedder,
+		scorer:     scorer,
+		retrieval:  retrieval,
+		compaction: compaction,
+		now:        now,
+		log: slog.Default().With(
+			logger.CategoryKey, logger.CategoryPlugin,
+			"name", "MemoryStore",
+			"agent", cfg.AgentID),
 	}
+	if err := m.load(); err != nil {
+		return nil, err
+	}
+	return m, nil
 }
 
+// Retrieve returns the most relevant memories with at least the given
+// importance, one per line
 func (m *MemoryStore) Retrieve(ctx context.Context, query string, threshold MemoryScore) (string, error) {
-	return "", nil
+	results, err := m.Search(ctx, query, m.retrieval.TopK)
+	if err != nil {
+		return "", err
+	}
+
+	var lines []string
+	for _, r := range results {
+		if r.Importance >= threshold {
+			lines = append(lines, r.Text())
+		}
+	}
+	return strings.Join(lines, "\n"), nil
 }
 
+// Store adds an observation with the given importance. Pass
+// MemoryScoreUnscored to have the configured scorer rate it.
 func (m *MemoryStore) Store(ctx context.Context, memory string, score MemoryScore) error {
+	_, err := m.Add(ctx, TimestampedMemory{
+		Content:    memory,
+		Type:       TypeObservation,
+		Importance: score,
+	})
+	return err
+}
+
+// Add appends a memory to the stream, filling in its ID, timestamp,
+// embedding and importance, and returns the stored memory
+func (m *MemoryStore) Add(ctx context.Context, mem TimestampedMemory) (TimestampedMemory, error) {
+	added, err := m.AddBatch(ctx, []TimestampedMemory{mem})
+	if err != nil {
+		return mem, err
+	}
+	return added[0], nil
+}
+
+// AddBatch appends several memories, scoring and embedding all of those that
+// need it in a single call each. The stream is compacted if this takes it
+// over capacity. A failed compaction is logged rather than returned, since
+// the memories were stored; the stream stays over capacity until the next
+// add tries again.
+func (m *MemoryStore) AddBatch(ctx context.Context, mems []TimestampedMemory) ([]TimestampedMemory, error) {
+	if err := m.prepare(ctx, mems); err != nil {
+		return nil, err
+	}
+
+	added := make([]TimestampedMemory, len(mems))
+	for i, mem := range mems {
+		var err error
+		if added[i], err = m.append(mem); err != nil {
+			return nil, err
+		}
+	}
+	if err := m.Compact(ctx); err != nil {
+		m.log.Warn("Compaction failed", "error", err, "memories", m.Len())
+	}
+	return added, nil
+}
+
+// prepare embeds and scores memories in place
+func (m *MemoryStore) prepare(ctx context.Context, mems []TimestampedMemory) error {
+	var toEmbed, toScore []string
+	var embedIdx, scoreIdx []int
+	for i, mem := range mems {
+		if mem.Embedding == nil {
+			toEmbed = append(toEmbed, mem.Text())
+			embedIdx = append(embedIdx, i)
+		}
+		if mem.Importance == MemoryScoreUnscored {
+			toScore = append(toScore, mem.Text())
+			scoreIdx = append(scoreIdx, i)
+		}
+	}
+
+	if len(toEmbed) > 0 {
+		embeddings, err := m.embedder.Embed(ctx, toEmbed)
+		if err != nil {
+			return fmt.Errorf("embed memory: %w", err)
+		}
+		for j, i := range embedIdx {
+			mems[i].Embedding = embeddings[j]
+		}
+	}
+
+	if len(toScore) > 0 {
+		scores, err := m.scorer.Score(ctx, toScore)
+		if err != nil {
+			return fmt.Errorf("score memory: %w", err)
+		}
+		for j, i := range scoreIdx {
+			mems[i].Importance = scores[j]
+		}
+	}
 	return nil
 }
+
+func (m *MemoryStore) append(mem TimestampedMemory) (TimestampedMemory, error) {
+	if mem.Type == "" {
+		mem.Type = TypeObservation
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if mem.Timestamp == 0 {
+		// A simulation clock only moves once per step, so memories stamped
+		// within a step are spaced a nanosecond apart to keep their order
+		mem.Timestamp = max(m.now().UnixNano(), m.lastStamp+1)
+		m.lastStamp = mem.Timestamp
+	}
+	if mem.LastAccessed == 0 {
+		mem.LastAccessed = mem.Timestamp
+	}
+
+	m.nextID++
+	if mem.ID == "" {
+		mem.ID = strconv.Itoa(m.nextID)
+	}
+	if err := m.persist(mem); err != nil {
+		return mem, err
+	}
+
+	// Keep the stream in timestamp order, like the store's keys, even when
+	// the caller backdates a memory
+	m.memories = append(m.memories, mem)
+	if n := len(m.memories); n > 1 && m.memories[n-2].Timestamp > mem.Timestamp {
+		sort.SliceStable(m.memories, func(i, j int) bool {
+			return m.memories[i].Timestamp < m.memories[j].Timestamp
+		})
+	}
+	return mem, nil
+}
+
+// Search ranks memories by a weighted sum of recency, importance and
+// relevance to query and returns the top k. Returned memories count as
+// accessed, which refreshes their recency.
+func (m *MemoryStore) Search(ctx context.Context, query string, k int) ([]ScoredMemory, error) {
+	var queryEmbedding []float32
+	if query != "" {
+		embeddings, err := m.embedder.Embed(ctx, []string{query})
+		if err != nil {
+			return nil, fmt.Errorf("embed query: %w", err)
+		}
+		queryEmbedding = embeddings[0]
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n := len(m.memories)
+	if n == 0 {
+		return nil, nil
+	}
+
+	now := m.now().UnixNano()
+	recency := make([]float64, n)
+	importance := make([]float64, n)
+	relevance := make([]float64, n)
+	for i, mem := range m.memories {
+		hours := float64(now-mem.LastAccessed) / float64(time.Hour)
+		recency[i] = math.Pow(m.retrieval.DecayFactor, max(hours, 0))
+		importance[i] = float64(mem.Importance) / float64(MemoryScoreMax)
+		if queryEmbedding != nil {
+			relevance[i] = llm.CosineSimilarity(queryEmbedding, mem.Embedding)
+		}
+	}
+	normalize(recency)
+	normalize(importance)
+	normalize(relevance)
+
+	scored := make([]ScoredMemory, n)
+	for i, mem := range m.memories {
+		scored[i] = ScoredMemory{
+			TimestampedMemory: mem,
+			Score: m.retrieval.RecencyWeight*recency[i] +
+				m.retrieval.ImportanceWeight*importance[i] +
+				m.retrieval.RelevanceWeight*relevance[i],
+		}
+	}
+	sort.SliceStable(scored, func(i, j int) bool {
+		return scored[i].Score > scored[j].Score
+	})
+	if k > 0 && len(scored) > k {
+		scored = scored[:k]
+	}
+
+	accessed := make(map[string]bool, len(scored))
+	for _, s := range scored {
+		accessed[s.ID] = true
+	}
+	var touched []TimestampedMemory
+	for i := range m.memories {
+		if accessed[m.memories[i].ID] {
+			m.memories[i].LastAccessed = now
+			m.memories[i].Retrievals++
+			touched = append(touched, m.memories[i])
+		}
+	}
+	if err := m.persist(touched...); err != nil {
+		return nil, err
+	}
+	return scored, nil
+}
+
+// Range returns the memories with timestamps in [from, to), oldest first.
+// With a store configured this is a key range scan.
+func (m *MemoryStore) Range(from, to time.Time) ([]TimestampedMemory, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.store == nil {
+		var ret []TimestampedMemory
+		for _, mem := range m.memories {
+			if mem.Timestamp >= from.UnixNano() && mem.Timestamp < to.UnixNano() {
+				ret = append(ret, mem)
+			}
+		}
+		return ret, nil
+	}
+
+	iter := m.store.NewIterator(&util.Range{
+		Start: m.timeKey(from.UnixNano()),
+		Limit: m.timeKey(to.UnixNano()),
+	}, nil)
+	defer iter.Release()
+	return decodeAll(iter)
+}
+
+func (m *MemoryStore) prefix() []byte {
+	// Escape so that agent "a" doesn't load the memories of agent "a/x"
+	return []byte(fmt.Sprintf("%s/%s/", KeyPrefix, url.PathEscape(m.agentID)))
+}
+
+// timeKey is the start of the key range for a timestamp. Timestamps are
+// zero padded so that keys sort chronologically.
+func (m *MemoryStore) timeKey(ts int64) []byte {
+	return append(m.prefix(), fmt.Sprintf("%020d/", max(ts, 0))...)
+}
+
+func (m *MemoryStore) key(mem TimestampedMemory) []byte {
+	return append(m.timeKey(mem.Timestamp), mem.ID...)
+}
+
+// persist writes memories to the store, if one is configured
+func (m *MemoryStore) persist(mems ...TimestampedMemory) error {
+	if m.store == nil || len(mems) == 0 {
+		return nil
+	}
+
+	batch := new(leveldb.Batch)
+	for _, mem := range mems {
+		b, err := json.Marshal(mem)
+		if err != nil {
+			return fmt.Errorf("marshal memory: %w", err)
+		}
+		batch.Put(m.key(mem), b)
+	}
+	if err := m.store.Write(batch, nil); err != nil {
+		return fmt.Errorf("persist memory: %w", err)
+	}
+	return nil
+}
+
+// load restores the agent's memories from the store
+func (m *MemoryStore) load() error {
+	if m.store == nil {
+		return nil
+	}
+
+	iter := m.store.NewIterator(util.BytesPrefix(m.prefix()), nil)
+	defer iter.Release()
+	memories, err := decodeAll(iter)
+	if err != nil {
+		return fmt.Errorf("load memories for %s: %w", m.agentID, err)
+	}
+
+	for _, mem := range memories {
+		if id, err := strconv.Atoi(mem.ID); err == nil && id > m.nextID {
+			m.nextID = id
+		}
+		m.lastStamp = max(m.lastStamp, mem.Timestamp)
+	}
+	m.memories = append(m.memories, memories...)
+	return nil
+}
+
+type iterator interface {
+	Next() bool
+	Value() []byte
+	Error() error
+}
+
+func decodeAll(iter iterator) ([]TimestampedMemory, error) {
+	var memories []TimestampedMemory
+	for iter.Next() {
+		var mem TimestampedMemory
+		if err := json.Unmarshal(iter.Value(), &mem); err != nil {
+			return nil, fmt.Errorf("decode memory: %w", err)
+		}
+		memories = append(memories, mem)
+	}
+	return memories, iter.Error()
+}
+
+// Len returns the number of memories in the stream
+func (m *MemoryStore) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.memories)
+}
+
+// Recent returns the latest n memories, oldest first
+func (m *MemoryStore) Recent(n int) []TimestampedMemory {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	start := max(len(m.memories)-n, 0)
+	return append([]TimestampedMemory(nil), m.memories[start:]...)
+}
+
+// importanceAfter sums the importance of the memories stamped after ts,
+// leaving out those of type skip
+func (m *MemoryStore) importanceAfter(ts int64, skip string) int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	sum := 0
+	for i := len(m.memories) - 1; i >= 0 && m.memories[i].Timestamp > ts; i-- {
+		if m.memories[i].Type != skip {
+			sum += int(m.memories[i].Importance)
+		}
+	}
+	return sum
+}
+
+// latest returns the timestamp of the newest memory of type typ, or 0
+func (m *MemoryStore) latest(typ string) int64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for i := len(m.memories) - 1; i >= 0; i-- {
+		if m.memories[i].Type == typ {
+			return m.memories[i].Timestamp
+		}
+	}
+	return 0
+}
+
+// normalize rescales values to [0, 1] in place. If the values are all
+// (nearly) equal they become 0 so the signal does not affect ranking, rather
+// than blowing tiny differences up to the full range.
+func normalize(values []float64) {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, v := range values {
+		lo = math.Min(lo, v)
+		hi = math.Max(hi, v)
+	}
+	span := hi - lo
+	for i, v := range values {
+		if span < 1e-9 {
+			values[i] = 0
+		} else {
+			values[i] = (v - lo) / span
+		}
+	}
+}
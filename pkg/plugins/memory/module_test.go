@@ -0,0 +1,275 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/storage"
+)
+
+func TestRetrievalConfigDefaults(t *testing.T) {
+	defaults := DefaultRetrievalConfig()
+	tests := []struct {
+		name string
+		cfg  RetrievalConfig
+		want RetrievalConfig
+	}{
+		{"zero", RetrievalConfig{}, defaults},
+		{
+			"only top k",
+			RetrievalConfig{TopK: 3},
+			RetrievalConfig{RecencyWeight: 1, ImportanceWeight: 1, RelevanceWeight: 1, DecayFactor: defaults.DecayFactor, TopK: 3},
+		},
+		{
+			"one weight off",
+			RetrievalConfig{RecencyWeight: 0, ImportanceWeight: 2, RelevanceWeight: 1},
+			RetrievalConfig{RecencyWeight: 0, ImportanceWeight: 2, RelevanceWeight: 1, DecayFactor: defaults.DecayFactor, TopK: defaults.TopK},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cfg.withDefaults(); got != tt.want {
+				t.Errorf("withDefaults() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchOrdering(t *testing.T) {
+	now := time.Now()
+	memories := []TimestampedMemory{
+		{Content: "Isabella is planning a Valentine's Day party at Hobbs Cafe", Importance: 3, LastAccessed: now.Add(-48 * time.Hour).UnixNano()},
+		{Content: "Klaus is reading a book on gentrification", Importance: 8, LastAccessed: now.Add(-24 * time.Hour).UnixNano()},
+		{Content: "The stove in the kitchen is on", Importance: 1, LastAccessed: now.Add(-time.Hour).UnixNano()},
+	}
+
+	tests := []struct {
+		name      string
+		retrieval RetrievalConfig
+		query     string
+		k         int
+		want      []string
+	}{
+		{
+			name:      "recency",
+			retrieval: RetrievalConfig{RecencyWeight: 1},
+			want:      []string{"3", "2", "1"},
+		},
+		{
+			name:      "importance",
+			retrieval: RetrievalConfig{ImportanceWeight: 1},
+			want:      []string{"2", "1", "3"},
+		},
+		{
+			name:      "relevance",
+			retrieval: RetrievalConfig{RelevanceWeight: 1},
+			query:     "party at Hobbs Cafe",
+			k:         1,
+			want:      []string{"1"},
+		},
+		{
+			name:      "relevance outweighs recency",
+			retrieval: RetrievalConfig{RecencyWeight: 1, RelevanceWeight: 3},
+			query:     "what is Klaus reading",
+			k:         2,
+			want:      []string{"2", "3"},
+		},
+		{
+			name:      "top k",
+			retrieval: RetrievalConfig{ImportanceWeight: 1},
+			k:         1,
+			want:      []string{"2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			m, err := NewMemoryStore(Config{
+				Retrieval: tt.retrieval,
+				Now:       func() time.Time { return now },
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, mem := range memories {
+				mem.Timestamp = mem.LastAccessed
+				if _, err := m.Add(ctx, mem); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			results, err := m.Search(ctx, tt.query, tt.k)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, r := range results {
+				got = append(got, r.ID)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Search() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("Search() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// clock is a simulation clock that only moves when told to
+type clock struct{ t time.Time }
+
+func (c *clock) Now() time.Time { return c.t }
+
+func newDB(t *testing.T) *leveldb.DB {
+	t.Helper()
+	db, err := leveldb.Open(storage.NewMemStorage(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestLoadRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	c := &clock{t: time.Date(2023, 2, 13, 9, 0, 0, 0, time.UTC)}
+
+	m, err := NewMemoryStore(Config{Store: db, AgentID: "a", Now: c.Now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Twelve memories in one tick, so that IDs 10-12 would sort before 2 if
+	// the clock alone decided the order
+	var want []string
+	for i := 1; i <= 12; i++ {
+		content := fmt.Sprintf("memory %d", i)
+		want = append(want, content)
+		if err := m.Store(ctx, content, MemoryScoreMedium); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reloaded, err := NewMemoryStore(Config{Store: db, AgentID: "a", Now: c.Now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := reloaded.Recent(100)
+	if fmt.Sprint(texts(got)) != fmt.Sprint(want) {
+		t.Fatalf("reloaded %v, want %v", texts(got), want)
+	}
+	for i, mem := range got {
+		orig := m.Recent(100)[i]
+		if mem.ID != orig.ID || mem.Timestamp != orig.Timestamp || mem.Importance != orig.Importance || len(mem.Embedding) != len(orig.Embedding) {
+			t.Fatalf("memory %d reloaded as %+v, want %+v", i, mem, orig)
+		}
+	}
+
+	next, err := reloaded.Add(ctx, TimestampedMemory{Content: "after restart", Importance: MemoryScoreLow})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.ID != "13" || next.Timestamp <= got[len(got)-1].Timestamp {
+		t.Errorf("memory after reload got ID %s at %d, want ID 13 after %d", next.ID, next.Timestamp, got[len(got)-1].Timestamp)
+	}
+}
+
+func TestLoadIsolatesAgents(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+
+	for _, id := range []string{"a", "a/x", "b"} {
+		m, err := NewMemoryStore(Config{Store: db, AgentID: id})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := m.Store(ctx, "memory of "+id, MemoryScoreMedium); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, id := range []string{"a", "a/x", "b"} {
+		m, err := NewMemoryStore(Config{Store: db, AgentID: id})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := texts(m.Recent(100))
+		if len(got) != 1 || got[0] != "memory of "+id {
+			t.Errorf("agent %q loaded %v", id, got)
+		}
+	}
+}
+
+func TestRange(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2023, 2, 13, 0, 0, 0, 0, time.UTC)
+	c := &clock{t: start}
+
+	stores := map[string]func(t *testing.T) Config{
+		"in memory": func(t *testing.T) Config { return Config{Now: c.Now} },
+		"leveldb":   func(t *testing.T) Config { return Config{Store: newDB(t), AgentID: "a", Now: c.Now} },
+	}
+
+	tests := []struct {
+		name     string
+		from, to time.Duration
+		want     []string
+	}{
+		{"everything", -time.Hour, 24 * time.Hour, []string{"0h", "1h", "2h", "3h"}},
+		{"inclusive start", time.Hour, 3 * time.Hour, []string{"1h", "2h"}},
+		{"exclusive end", 0, time.Hour, []string{"0h"}},
+		{"empty window", 90 * time.Minute, 2 * time.Hour, nil},
+		{"before the first", -2 * time.Hour, -time.Hour, nil},
+	}
+
+	for storeName, config := range stores {
+		t.Run(storeName, func(t *testing.T) {
+			m, err := NewMemoryStore(config(t))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for h := 0; h < 4; h++ {
+				c.t = start.Add(time.Duration(h) * time.Hour)
+				if err := m.Store(ctx, fmt.Sprintf("%dh", h), MemoryScoreMedium); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					got, err := m.Range(start.Add(tt.from), start.Add(tt.to))
+					if err != nil {
+						t.Fatal(err)
+					}
+					if fmt.Sprint(texts(got)) != fmt.Sprint(tt.want) {
+						t.Errorf("Range() = %v, want %v", texts(got), tt.want)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestZeroClockFallsBack(t *testing.T) {
+	// Simulation.Now returns the zero time until StartTime is configured
+	m, err := NewMemoryStore(Config{Now: func() time.Time { return time.Time{} }})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before := time.Now()
+	mem, err := m.Add(context.Background(), TimestampedMemory{Content: "hello", Type: TypeObservation})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts := time.Unix(0, mem.Timestamp); ts.Before(before) || ts.After(time.Now()) {
+		t.Errorf("Timestamp = %v, want wall-clock time", ts)
+	}
+}
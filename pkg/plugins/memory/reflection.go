@@ -0,0 +1,307 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/syndtr/goleveldb/leveldb"
+
+	"simulacra/pkg/llm"
+	"simulacra/pkg/llm/prompt"
+)
+
+// ReflectionConfig configures periodic reflection, where the agent
+// synthesizes higher-level insights from its recent memories
+type ReflectionConfig struct {
+	Provider llm.Provider
+	Model    string
+	// Prompts defaults to the embedded template library
+	Prompts *prompt.Library
+
+	// Name and Persona describe the reflecting agent in prompts
+	Name    string
+	Persona string
+
+	// Threshold is the summed importance of memories added since the last
+	// reflection that triggers the next one
+	Threshold int
+	// Questions is the number of salient questions asked per reflection
+	Questions int
+	// Recent is the number of latest memories the questions are drawn from
+	Recent int
+}
+
+// DefaultReflectionConfig returns the settings used in the paper
+func DefaultReflectionConfig() ReflectionConfig {
+	return ReflectionConfig{
+		Threshold: 150,
+		Questions: 3,
+		Recent:    100,
+	}
+}
+
+// Reflector turns a memory stream's recent experience into reflection
+// memories, each linked to the memories it was inferred from
+type Reflector struct {
+	memory *MemoryStore
+	cfg    ReflectionConfig
+
+	// watermark is the timestamp of the newest memory considered by the
+	// last reflection attempt, successful or not. Only memories after it
+	// count towards the next one.
+	watermark atomic.Int64
+
+	// Serialises reflections so one trigger does not produce two
+	mu sync.Mutex
+}
+
+// NewReflector creates a reflector over memory. Zero fields in cfg take
+// their defaults from DefaultReflectionConfig.
+func NewReflector(memory *MemoryStore, cfg ReflectionConfig) (*Reflector, error) {
+	if cfg.Provider == nil {
+		return nil, fmt.Errorf("reflection requires an LLM provider")
+	}
+
+	defaults := DefaultReflectionConfig()
+	if cfg.Threshold == 0 {
+		cfg.Threshold = defaults.Threshold
+	}
+	if cfg.Questions == 0 {
+		cfg.Questions = defaults.Questions
+	}
+	if cfg.Recent == 0 {
+		cfg.Recent = defaults.Recent
+	}
+	if cfg.Prompts == nil {
+		prompts, err := prompt.NewLibrary()
+		if err != nil {
+			return nil, err
+		}
+		cfg.Prompts = prompts
+	}
+
+	r := &Reflector{
+		memory: memory,
+		cfg:    cfg,
+	}
+	watermark, err := r.loadWatermark()
+	if err != nil {
+		return nil, err
+	}
+	r.watermark.Store(watermark)
+	return r, nil
+}
+
+// Pending returns the summed importance of memories added since the last
+// reflection attempt. Reflections themselves don't count.
+func (r *Reflector) Pending() int {
+	return r.memory.importanceAfter(r.watermark.Load(), TypeReflection)
+}
+
+// Due reports whether enough has happened to warrant a reflection
+func (r *Reflector) Due() bool {
+	return r.Pending() >= r.cfg.Threshold
+}
+
+type reflectionQuestions struct {
+	Questions []string `json:"questions" validate:"required,min=1,dive,required"`
+}
+
+type insight struct {
+	Insight string `json:"insight" validate:"required"`
+	Sources []int  `json:"sources" jsonschema:"description=Numbers of the statements the insight is based on"`
+}
+
+type reflectionInsights struct {
+	Insights []insight `json:"insights" validate:"required,min=1,dive"`
+}
+
+// Reflect asks the salient questions raised by recent memories, retrieves
+// the memories relevant to each, and stores the insights inferred from them
+// as TypeReflection memories. The stored reflections are returned.
+func (r *Reflector) Reflect(ctx context.Context) ([]TimestampedMemory, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	recent := r.memory.Recent(r.cfg.Recent)
+	if len(recent) == 0 {
+		return nil, nil
+	}
+
+	// Move past these memories even if the attempt fails, so a failing
+	// model is not asked again after every thought
+	if err := r.setWatermark(recent[len(recent)-1].Timestamp); err != nil {
+		return nil, err
+	}
+
+	questions, err := r.questions(ctx, recent)
+	if err != nil {
+		return nil, err
+	}
+
+	var reflections []TimestampedMemory
+	for _, q := range questions {
+		evidence, err := r.memory.Search(ctx, q, r.memory.retrieval.TopK)
+		if err != nil {
+			return nil, err
+		}
+		if len(evidence) == 0 {
+			continue
+		}
+
+		found, err := r.insights(ctx, q, evidence)
+		if err != nil {
+			return nil, err
+		}
+		reflections = append(reflections, found...)
+	}
+	if len(reflections) == 0 {
+		return nil, nil
+	}
+	return r.memory.AddBatch(ctx, reflections)
+}
+
+// questions generates the salient questions to reflect on
+func (r *Reflector) questions(ctx context.Context, recent []TimestampedMemory) ([]string, error) {
+	tmpl, err := r.cfg.Prompts.Get(prompt.NameQuestions)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := tmpl.Render(prompt.Data{
+		Name:     r.cfg.Name,
+		Persona:  r.cfg.Persona,
+		Memories: texts(recent),
+		Count:    r.cfg.Questions,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := llm.Structured[reflectionQuestions](ctx, r.cfg.Provider, llm.ChatRequest{
+		Model:    r.cfg.Model,
+		Messages: messages,
+		PromptID: tmpl.ID(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("generate reflection questions: %w", err)
+	}
+
+	questions := out.Questions
+	if len(questions) > r.cfg.Questions {
+		questions = questions[:r.cfg.Questions]
+	}
+	return questions, nil
+}
+
+// insights infers insights about question from the evidence, linking each
+// back to the memories it cites
+func (r *Reflector) insights(ctx context.Context, question string, evidence []ScoredMemory) ([]TimestampedMemory, error) {
+	tmpl, err := r.cfg.Prompts.Get(prompt.NameReflection)
+	if err != nil {
+		return nil, err
+	}
+	statements := make([]string, len(evidence))
+	for i, e := range evidence {
+		statements[i] = e.Text()
+	}
+	messages, err := tmpl.Render(prompt.Data{
+		Name:     r.cfg.Name,
+		Persona:  r.cfg.Persona,
+		Memories: statements,
+		Question: question,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := llm.Structured[reflectionInsights](ctx, r.cfg.Provider, llm.ChatRequest{
+		Model:    r.cfg.Model,
+		Messages: messages,
+		PromptID: tmpl.ID(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("synthesize reflection: %w", err)
+	}
+
+	reflections := make([]TimestampedMemory, 0, len(out.Insights))
+	for _, in := range out.Insights {
+		// Statements are numbered from 1 in the prompt
+		var sources []string
+		for _, n := range in.Sources {
+			if n >= 1 && n <= len(evidence) {
+				sources = append(sources, evidence[n-1].ID)
+			}
+		}
+		reflections = append(reflections, TimestampedMemory{
+			Content: in.Insight,
+			Type:    TypeReflection,
+			Metadata: map[string]interface{}{
+				"question": question,
+				"sources":  sources,
+			},
+		})
+	}
+	return reflections, nil
+}
+
+// loadWatermark restores the watermark from the store. Without one it starts
+// at the newest reflection, if any.
+func (r *Reflector) loadWatermark() (int64, error) {
+	if r.memory.store != nil {
+		b, err := r.memory.store.Get(r.watermarkKey(), nil)
+		if err == nil {
+			return strconv.ParseInt(string(b), 10, 64)
+		}
+		if !errors.Is(err, leveldb.ErrNotFound) {
+			return 0, fmt.Errorf("load reflection watermark: %w", err)
+		}
+	}
+	return r.memory.latest(TypeReflection), nil
+}
+
+func (r *Reflector) setWatermark(ts int64) error {
+	r.watermark.Store(ts)
+	if r.memory.store == nil {
+		return nil
+	}
+	if err := r.memory.store.Put(r.watermarkKey(), []byte(strconv.FormatInt(ts, 10)), nil); err != nil {
+		return fmt.Errorf("persist reflection watermark: %w", err)
+	}
+	return nil
+}
+
+func (r *Reflector) watermarkKey() []byte {
+	return []byte(fmt.Sprintf("%s/%s", WatermarkKeyPrefix, url.PathEscape(r.memory.agentID)))
+}
+
+// Sources returns the IDs of the memories a reflection was inferred from,
+// or a summary was merged from
+func (m TimestampedMemory) Sources() []string {
+	switch sources := m.Metadata["sources"].(type) {
+	case []string:
+		return sources
+	case []interface{}:
+		// Decoded from the store
+		ids := make([]string, 0, len(sources))
+		for _, s := range sources {
+			if id, ok := s.(string); ok {
+				ids = append(ids, id)
+			}
+		}
+		return ids
+	}
+	return nil
+}
+
+func texts(mems []TimestampedMemory) []string {
+	ret := make([]string, len(mems))
+	for i, m := range mems {
+		ret[i] = m.Text()
+	}
+	return ret
+}
@@ -0,0 +1,113 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"simulacra/pkg/llm"
+)
+
+// reflector answers the question and insight prompts of a reflection, or
+// fails every call when err is set
+type reflector struct {
+	err   error
+	calls int
+}
+
+func (r *reflector) Name() string { return "reflector" }
+
+func (r *reflector) ChatCompletion(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	r.calls++
+	if r.err != nil {
+		return nil, r.err
+	}
+	if strings.HasPrefix(req.PromptID, "questions@") {
+		return &llm.ChatResponse{Content: `{"questions": ["What is Klaus working on?"]}`}, nil
+	}
+	return &llm.ChatResponse{Content: `{"insights": [{"insight": "Klaus is dedicated to his research", "sources": [1, 2, 7]}]}`}, nil
+}
+
+func TestReflect(t *testing.T) {
+	ctx := context.Background()
+	m, err := NewMemoryStore(Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReflector(m, ReflectionConfig{Provider: &reflector{}, Threshold: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []string{"Klaus is writing a research paper", "Klaus is reading about gentrification"} {
+		if err := m.Store(ctx, s, MemoryScoreMedium); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !r.Due() {
+		t.Fatalf("Due() = false with %d pending", r.Pending())
+	}
+
+	reflections, err := r.Reflect(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reflections) != 1 || reflections[0].Type != TypeReflection {
+		t.Fatalf("Reflect() = %+v, want one reflection", reflections)
+	}
+	// Statement 7 does not exist and is dropped
+	if sources := reflections[0].Sources(); len(sources) != 2 {
+		t.Errorf("Sources() = %v, want the two statements cited", sources)
+	}
+	if r.Pending() != 0 {
+		t.Errorf("Pending() = %d after reflecting, want 0", r.Pending())
+	}
+}
+
+func TestReflectFailureAdvancesWatermark(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	m, err := NewMemoryStore(Config{Store: db, AgentID: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := &reflector{err: errors.New("model unavailable")}
+	r, err := NewReflector(m, ReflectionConfig{Provider: provider, Threshold: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := m.Store(ctx, fmt.Sprintf("memory %d", i), MemoryScoreMedium); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := r.Reflect(ctx); err == nil {
+		t.Fatal("Reflect() succeeded with a failing model")
+	}
+	if r.Due() {
+		t.Errorf("Due() = true right after a failed attempt, with %d pending", r.Pending())
+	}
+
+	// The watermark survives a restart
+	m, err = NewMemoryStore(Config{Store: db, AgentID: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err = NewReflector(m, ReflectionConfig{Provider: provider, Threshold: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Pending() != 0 {
+		t.Errorf("Pending() = %d after restart, want 0", r.Pending())
+	}
+
+	if err := m.Store(ctx, "something new", MemoryScoreHigh); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.Pending(); got != int(MemoryScoreHigh) {
+		t.Errorf("Pending() = %d, want only the new memory", got)
+	}
+}
@@ -0,0 +1,157 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"simulacra/pkg/core/logger"
+	"simulacra/pkg/llm"
+	"simulacra/pkg/llm/prompt"
+)
+
+// MemoryScoreUnscored asks the store to rate a memory's importance itself
+const MemoryScoreUnscored MemoryScore = 0
+
+// ImportanceScorer rates the poignancy of memories on a 1-10 scale
+type ImportanceScorer interface {
+	Score(ctx context.Context, memories []string) ([]MemoryScore, error)
+}
+
+// clampScore maps any rating onto the 1-10 MemoryScore range
+func clampScore(score int) MemoryScore {
+	return MemoryScore(min(max(score, int(MemoryScoreLow)), int(MemoryScoreMax)))
+}
+
+// LLMScorer asks a model to rate a whole batch of memories in one call. If
+// the model fails to give one valid rating per memory, the batch is rated by
+// HeuristicScorer instead, so a bad answer never loses memories.
+type LLMScorer struct {
+	provider llm.Provider
+	model    string
+	prompts  *prompt.Library
+	name     string
+	fallback ImportanceScorer
+	log      *slog.Logger
+}
+
+var _ ImportanceScorer = &LLMScorer{}
+
+// NewLLMScorer creates a scorer. prompts defaults to the embedded template
+// library. agentName, if set, lets the model judge poignancy from that
+// agent's point of view.
+func NewLLMScorer(provider llm.Provider, model string, prompts *prompt.Library, agentName string) (*LLMScorer, error) {
+	if provider == nil {
+		return nil, fmt.Errorf("importance scoring requires an LLM provider")
+	}
+	if prompts == nil {
+		var err error
+		if prompts, err = prompt.NewLibrary(); err != nil {
+			return nil, err
+		}
+	}
+
+	return &LLMScorer{
+		provider: provider,
+		model:    model,
+		prompts:  prompts,
+		name:     agentName,
+		fallback: HeuristicScorer{},
+		log: slog.Default().With(
+			logger.CategoryKey, logger.CategoryPlugin,
+			"name", "LLMScorer"),
+	}, nil
+}
+
+type importanceRatings struct {
+	Scores []int `json:"scores" validate:"required" jsonschema:"description=One 1-10 rating per memory, in order"`
+}
+
+func (s *LLMScorer) Score(ctx context.Context, memories []string) ([]MemoryScore, error) {
+	if len(memories) == 0 {
+		return nil, nil
+	}
+
+	scores, err := s.rate(ctx, memories)
+	if err != nil {
+		s.log.Warn("Falling back to heuristic importance", "memories", len(memories), "error", err)
+		return s.fallback.Score(ctx, memories)
+	}
+	return scores, nil
+}
+
+func (s *LLMScorer) rate(ctx context.Context, memories []string) ([]MemoryScore, error) {
+	tmpl, err := s.prompts.Get(prompt.NameImportance)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := tmpl.Render(prompt.Data{Name: s.name, Memories: memories})
+	if err != nil {
+		return nil, err
+	}
+
+	ratings, err := llm.Structured[importanceRatings](ctx, s.provider, llm.ChatRequest{
+		Model:    s.model,
+		Messages: messages,
+		PromptID: tmpl.ID(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("importance scoring failed: %w", err)
+	}
+	if len(ratings.Scores) != len(memories) {
+		return nil, fmt.Errorf("importance scoring returned %d scores for %d memories", len(ratings.Scores), len(memories))
+	}
+
+	scores := make([]MemoryScore, len(memories))
+	for i, r := range ratings.Scores {
+		scores[i] = clampScore(r)
+	}
+	return scores, nil
+}
+
+// HeuristicScorer rates memories offline by looking for words that tend to
+// mark significant events and strong feelings. It is crude but deterministic
+// and free.
+type HeuristicScorer struct{}
+
+var _ ImportanceScorer = HeuristicScorer{}
+
+// poignantWords raise a memory's score by the given amount
+var poignantWords = map[string]int{
+	// life events
+	"died": 5, "death": 5, "funeral": 5, "born": 4, "married": 5, "wedding": 4,
+	"divorce": 5, "pregnant": 4, "accepted": 3, "rejected": 3, "fired": 4,
+	"hired": 3, "promotion": 3, "graduated": 3, "moved": 2, "election": 3,
+	"broke": 3, "accident": 4, "hospital": 4, "sick": 2,
+	// relationships and emotions
+	"love": 3, "hate": 3, "fight": 3, "argument": 3, "argued": 3, "kissed": 3,
+	"betrayed": 4, "secret": 2, "apologized": 2, "cried": 3, "angry": 2,
+	"afraid": 2, "excited": 2, "proud": 2, "ashamed": 2, "lonely": 2,
+	// plans and goals
+	"party": 2, "plan": 1, "planning": 1, "goal": 1, "decided": 2, "promise": 2,
+}
+
+// mundaneWords lower a memory's score
+var mundaneWords = map[string]int{
+	"idle": 1, "sleeping": 1, "waiting": 1, "brushing": 1, "walking": 1,
+	"no-op": 1, "nothing": 1,
+}
+
+func (HeuristicScorer) Score(ctx context.Context, memories []string) ([]MemoryScore, error) {
+	scores := make([]MemoryScore, len(memories))
+	for i, m := range memories {
+		score := 2
+		for _, w := range strings.FieldsFunc(strings.ToLower(m), func(r rune) bool {
+			return r == ' ' || r == ',' || r == '.' || r == ':' || r == ';' || r == '"' || r == '\n'
+		}) {
+			score += poignantWords[strings.Trim(w, "!?'")]
+			score -= mundaneWords[w]
+		}
+		if strings.Contains(m, "!") {
+			score++
+		}
+		scores[i] = clampScore(score)
+	}
+	return scores, nil
+}
@@ -0,0 +1,64 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"simulacra/pkg/llm"
+)
+
+// answer replies to every request with the same content
+type answer string
+
+func (a answer) Name() string { return "answer" }
+
+func (a answer) ChatCompletion(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	return &llm.ChatResponse{Content: string(a)}, nil
+}
+
+func TestLLMScorer(t *testing.T) {
+	memories := []string{"brushing teeth", "Maria died!"}
+	heuristic, _ := HeuristicScorer{}.Score(context.Background(), memories)
+
+	tests := []struct {
+		name  string
+		reply string
+		want  []MemoryScore
+	}{
+		{"rated", `{"scores": [2, 9]}`, []MemoryScore{2, 9}},
+		{"clamped", `{"scores": [0, 14]}`, []MemoryScore{MemoryScoreLow, MemoryScoreMax}},
+		{"wrong count", `{"scores": [3]}`, heuristic},
+		{"not json", `no idea`, heuristic},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A nil library falls back to the embedded templates
+			s, err := NewLLMScorer(answer(tt.reply), "", nil, "Klaus")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := s.Score(context.Background(), memories)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("Score() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigProviderScores(t *testing.T) {
+	m, err := NewMemoryStore(Config{Provider: answer(`{"scores": [9]}`), Name: "Klaus"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mem, err := m.Add(context.Background(), TimestampedMemory{Content: "Maria died!", Type: TypeObservation})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mem.Importance != 9 {
+		t.Errorf("Importance = %d, want the LLM's rating", mem.Importance)
+	}
+}